@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -75,6 +77,321 @@ type AppServiceSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
 	// +kubebuilder:validation:Pattern=`^(?:[_a-z0-9](?:[_a-z0-9-]{0,61}[a-z0-9]\.)|(?:[0-9]+/[0-9]{2})\.)+(?:[a-z](?:[a-z0-9-]{0,61}[a-z0-9])?)?$`
 	DomainName string `json:"domainName,omitempty"`
+
+	// Backup schedules periodic backups of the Events Database
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Backup"
+	// +optional
+	Backup *BackupSpec `json:"backup,omitempty"`
+
+	// Database configures the Events Database
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Database"
+	// +optional
+	Database *DatabaseSpec `json:"database,omitempty"`
+
+	// SeedData is loaded once into the events table if it's empty, after the schema is up to date
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Seed Data"
+	// +optional
+	SeedData *SeedDataSpec `json:"seedData,omitempty"`
+}
+
+// SeedDataSampleSet names a set of sample events built into the operator
+type SeedDataSampleSet string
+
+// SeedDataSampleSets defined here
+const (
+	SeedDataSampleSetDefault SeedDataSampleSet = "Default"
+)
+
+// SeedDataSpec defines where the events to seed the Events Database with come from, one of SampleSet or ConfigMap
+type SeedDataSpec struct {
+	// SampleSet built into the operator
+	// +kubebuilder:validation:Enum=Default
+	// +optional
+	SampleSet SeedDataSampleSet `json:"sampleSet,omitempty"`
+
+	// ConfigMap whose keys are JSON documents with an event or a list of events, as posted to the gateway /api/events.
+	// Events without a date get the day they're loaded
+	// +optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+}
+
+// DatabaseSpec defines how the Events Database is set up
+type DatabaseSpec struct {
+	// CredentialsSecretRef points to a Secret with the keys database-user, database-password and database-name to be used
+	// instead of the credentials generated by the operator. It should be set before the database is initialized and
+	// it's ignored if External is set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Credentials Secret"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:io.kubernetes:Secret"
+	// +optional
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+
+	// RotateCredentials triggers a rotation of the Events Database password every time it's increased. Only the
+	// credentials generated by the operator are rotated, with CredentialsSecretRef or External set the rotation is
+	// refused and reported in the CredentialsRotationRefused condition. A failed rotation is reported in the
+	// CredentialsRotationFailed condition and retried once its Job is deleted
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Rotate Credentials"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:number"
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RotateCredentials int64 `json:"rotateCredentials,omitempty"`
+
+	// External points the Events Service to an existing PostgreSQL server instead of deploying one
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="External Database"
+	// +optional
+	External *ExternalDatabaseSpec `json:"external,omitempty"`
+
+	// Storage of the Events Database volume. The backups volume grows along with it, it's twice its size and 1Gi at least
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Storage"
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Workload the Events Database runs as, Deployment if not set. Moving from Deployment to StatefulSet copies the
+	// data to the volume of the StatefulSet, moving back to Deployment isn't supported
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Workload"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:Deployment"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:StatefulSet"
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// +optional
+	Workload DatabaseWorkload `json:"workload,omitempty"`
+
+	// Replicas is the number of streaming replication standbys of the Events Database, they're reachable for
+	// read-only traffic through the events-database-readonly Service. Not supported with an External database
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Replicas"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:podCount"
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=5
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Promote names the replica pod to fail over to, i.e. events-database-replica-0. The primary is fenced by scaling
+	// it down before the replica is promoted, and the events-database Service is repointed to it. Only the original
+	// primary can be failed over, the promoted replica keeps the primary role from then on
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Promote"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	// +optional
+	Promote string `json:"promote,omitempty"`
+
+	// MigrationMode sets how pending update scripts are run. Auto runs them right away, DryRun runs them in a
+	// transaction that is always rolled back and reports the outcome in status.database.dryRun, Manual runs them
+	// up to the version set in the gramola.atarazana.com/approve-migration annotation. Auto if not set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Migration Mode"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:Auto"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:DryRun"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:Manual"
+	// +kubebuilder:validation:Enum=Auto;DryRun;Manual
+	// +optional
+	MigrationMode MigrationMode `json:"migrationMode,omitempty"`
+
+	// RollbackTo brings the schema back to the given version by running, newest first, the down script of every
+	// update script applied above it. Update scripts above this version aren't run while it's set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Rollback To"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	// +optional
+	RollbackTo string `json:"rollbackTo,omitempty"`
+
+	// ScriptVariables are given to update scripts as {{.Variables.<name>}}, scripts referring to a variable not set
+	// here fail to render. Changing a variable used by an applied script is reported as a MigrationDrift
+	// +optional
+	ScriptVariables map[string]string `json:"scriptVariables,omitempty"`
+
+	// CustomScripts are ConfigMaps whose keys are update scripts named <name>-<version>.sql, with optional down
+	// scripts named <name>-<version>-down.sql. They're run in order after the built-in scripts and tracked as
+	// <configmap>:<version>, which is also the version to approve them with in Manual migration mode. That version
+	// can't be longer than 50 characters
+	// +optional
+	CustomScripts []corev1.LocalObjectReference `json:"customScripts,omitempty"`
+
+	// Pooler deploys PgBouncer in front of the Events Database, the Events Service connects through it. The operator
+	// and its Jobs keep connecting to the database directly
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Pooler"
+	// +optional
+	Pooler *PoolerSpec `json:"pooler,omitempty"`
+
+	// Version is the PostgreSQL major version of the Events Database, 10 if not set. Raising it dumps the database
+	// into a new volume served by the new version and switches over to it, the old volume is kept until the upgrade
+	// is confirmed with the gramola.atarazana.com/confirm-database-upgrade annotation. Until then setting the previous
+	// version back rolls the upgrade back. Downgrades aren't supported, neither are upgrades of a StatefulSet or a
+	// replicated database
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Version"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:10"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:12"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:select:13"
+	// +kubebuilder:validation:Enum="10";"12";"13"
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// PoolMode defines when PgBouncer gives a server connection back to the pool
+type PoolMode string
+
+// PoolModes defined here
+const (
+	PoolModeSession     PoolMode = "Session"
+	PoolModeTransaction PoolMode = "Transaction"
+	PoolModeStatement   PoolMode = "Statement"
+)
+
+// PoolerSpec defines the PgBouncer connection pooler of the Events Database, its configuration is generated from the
+// Events Database credentials
+type PoolerSpec struct {
+	// Replicas of the pooler, 1 if not set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Replicas"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:podCount"
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// PoolMode of the pooler, Transaction if not set. Clients that rely on server side prepared statements, like the
+	// PostgreSQL JDBC driver by default, need Session mode or to disable them
+	// +kubebuilder:validation:Enum=Session;Transaction;Statement
+	// +optional
+	PoolMode PoolMode `json:"poolMode,omitempty"`
+
+	// DefaultPoolSize is the number of server connections per user and database, 20 if not set
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DefaultPoolSize int32 `json:"defaultPoolSize,omitempty"`
+
+	// MaxClientConnections is the number of client connections allowed, 100 if not set
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxClientConnections int32 `json:"maxClientConnections,omitempty"`
+}
+
+// MigrationMode defines how pending update scripts are run
+type MigrationMode string
+
+// MigrationModes defined here
+const (
+	MigrationModeAuto   MigrationMode = "Auto"
+	MigrationModeDryRun MigrationMode = "DryRun"
+	MigrationModeManual MigrationMode = "Manual"
+)
+
+// DatabaseSSLMode defines how connections to the Events Database are secured with TLS, as in libpq sslmode
+type DatabaseSSLMode string
+
+// DatabaseSSLModes defined here
+const (
+	DatabaseSSLModeDisable    DatabaseSSLMode = "disable"
+	DatabaseSSLModeRequire    DatabaseSSLMode = "require"
+	DatabaseSSLModeVerifyCA   DatabaseSSLMode = "verify-ca"
+	DatabaseSSLModeVerifyFull DatabaseSSLMode = "verify-full"
+)
+
+// DatabaseWorkload defines the kinds of workload the Events Database can run as
+type DatabaseWorkload string
+
+// DatabaseWorkloads defined here
+const (
+	DatabaseWorkloadDeployment  DatabaseWorkload = "Deployment"
+	DatabaseWorkloadStatefulSet DatabaseWorkload = "StatefulSet"
+)
+
+// StorageSpec defines a volume of the Events Database, its data or its backups. Only Size can be changed once the
+// volume is created, and only to grow it if its StorageClass allows volume expansion
+type StorageSpec struct {
+	// Size of the volume, 512Mi for the data of the Events Database if not set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Size"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName of the volume, the default StorageClass if not set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Storage Class"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:io.kubernetes:StorageClass"
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes of the volume, ReadWriteOnce if not set
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// VolumeMode of the volume, Filesystem if not set
+	// +kubebuilder:validation:Enum=Filesystem;Block
+	// +optional
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+}
+
+// ExternalDatabaseSpec defines how to connect to an external PostgreSQL server
+type ExternalDatabaseSpec struct {
+	// Host name or IP address of the server
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port the server listens on, 5432 if not set
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// DatabaseName of the Events Database in the server
+	// +kubebuilder:validation:MinLength=1
+	DatabaseName string `json:"databaseName"`
+
+	// CredentialsSecretRef points to a Secret with the keys database-user and database-password
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+
+	// SSLMode sets how connections to the server are secured with TLS, require if not set. verify-ca and verify-full
+	// also check the certificate of the server against the authority in CASecretRef
+	// +kubebuilder:validation:Enum=disable;require;verify-ca;verify-full
+	// +optional
+	SSLMode DatabaseSSLMode `json:"sslMode,omitempty"`
+
+	// CASecretRef points to a Secret with the key ca.crt, the certificate of the authority that signed the
+	// certificate of the server
+	// +optional
+	CASecretRef *corev1.LocalObjectReference `json:"caSecretRef,omitempty"`
+}
+
+// BackupSpec defines when scheduled backups are taken and how long they are kept
+type BackupSpec struct {
+	// Schedule in Cron format, e.g. "0 2 * * *"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Suspend stops scheduling new backups while keeping the existing ones
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Retention of the scheduled backups, all of them are kept if not set
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+
+	// Storage of the volume the scheduled backups are kept in, apart from the other backups so that they can't fill
+	// the volume the pre-update backups need. It's as big as the backups volume if no size is set, and it's kept
+	// when the scheduled backups are removed
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+}
+
+// BackupRetention defines which scheduled backups are kept, a backup is pruned if it breaks any of the limits set
+type BackupRetention struct {
+	// Count is the maximum number of scheduled backups kept
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+
+	// MaxAge is the maximum age of the scheduled backups kept, e.g. "168h"
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 }
 
 // AppServiceConditionType defines the potential condition types
@@ -82,7 +399,12 @@ type AppServiceConditionType string
 
 // AppServiceConditionTypes defined here
 const (
-	AppServiceConditionTypePromoted AppServiceConditionType = "Promoted"
+	AppServiceConditionTypePromoted       AppServiceConditionType = "Promoted"
+	AppServiceConditionTypeFailover       AppServiceConditionType = "Failover"
+	AppServiceConditionTypeMigrationDrift AppServiceConditionType = "MigrationDrift"
+
+	AppServiceConditionTypeCredentialsRotationRefused AppServiceConditionType = "CredentialsRotationRefused"
+	AppServiceConditionTypeCredentialsRotationFailed  AppServiceConditionType = "CredentialsRotationFailed"
 )
 
 // AppServiceConditionReason defines the potential condition reasons
@@ -112,7 +434,7 @@ const (
 // AppServiceCondition defines the desired state
 type AppServiceCondition struct {
 	// Type of replication controller condition.
-	// +kubebuilder:validation:Enum=Promoted
+	// +kubebuilder:validation:Enum=Promoted;Failover;MigrationDrift;CredentialsRotationRefused;CredentialsRotationFailed
 	Type AppServiceConditionType `json:"type" protobuf:"bytes,1,opt,name=type,casttype=AppServiceConditionType"`
 	// Status of the condition, one of True, False, Unknown.
 	// +kubebuilder:validation:Enum=True;False;Unknown
@@ -155,9 +477,10 @@ type DatabaseUpdateStatus string
 
 // DatabaseUpdateStatuses defined here
 const (
-	DatabaseUpdateStatusSucceeded DatabaseUpdateStatus = "Succeeded"
-	DatabaseUpdateStatusFailed    DatabaseUpdateStatus = "Failed"
-	DatabaseUpdateStatusUnknown   DatabaseUpdateStatus = "Unknown"
+	DatabaseUpdateStatusSucceeded  DatabaseUpdateStatus = "Succeeded"
+	DatabaseUpdateStatusFailed     DatabaseUpdateStatus = "Failed"
+	DatabaseUpdateStatusUnknown    DatabaseUpdateStatus = "Unknown"
+	DatabaseUpdateStatusRolledBack DatabaseUpdateStatus = "RolledBack"
 )
 
 // DatabaseScriptRun logs script run and status
@@ -165,9 +488,303 @@ type DatabaseScriptRun struct {
 	// Script
 	Script string `json:"script"`
 
-	// Status of the run of the Script
-	// +kubebuilder:validation:Enum=Succeeded;Failed;Unknown
+	// Version of the schema the Script updates the database to
+	Version string `json:"version,omitempty"`
+
+	// Checksum is the SHA-256 of the Script as it was run, changes to an applied Script are reported as a MigrationDrift
+	Checksum string `json:"checksum,omitempty"`
+
+	// Status of the run of the Script, RolledBack once its down script undid it
+	// +kubebuilder:validation:Enum=Succeeded;Failed;Unknown;RolledBack
 	Status DatabaseUpdateStatus `json:"eventsDatabaseUpdated,omitempty"`
+
+	// StartTime records when the run of the Script started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the run of the Script finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration of the run of the Script
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Error reported by the database if the run of the Script failed
+	Error *DatabaseScriptError `json:"error,omitempty"`
+}
+
+// DatabaseScriptError is the error reported by the database when a script fails
+type DatabaseScriptError struct {
+	// SQLState is the PostgreSQL error code, empty if the error didn't come from the server
+	SQLState string `json:"sqlState,omitempty"`
+
+	// Message of the error
+	Message string `json:"message"`
+
+	// Line of the script where the failing statement is
+	Line int `json:"line,omitempty"`
+
+	// Detail of the error if the database gave any
+	Detail string `json:"detail,omitempty"`
+
+	// Hint to fix the error if the database gave any
+	Hint string `json:"hint,omitempty"`
+}
+
+// DatabaseBackupStatus defines the potential status of a database backup
+type DatabaseBackupStatus string
+
+// DatabaseBackupStatuses defined here
+const (
+	DatabaseBackupStatusSucceeded DatabaseBackupStatus = "Succeeded"
+	DatabaseBackupStatusFailed    DatabaseBackupStatus = "Failed"
+	DatabaseBackupStatusUnknown   DatabaseBackupStatus = "Unknown"
+)
+
+// DatabaseBackup logs a backup of the database and where it was stored
+type DatabaseBackup struct {
+	// Name of the Job that took the backup
+	Job string `json:"job"`
+
+	// PersistentVolumeClaim where the backup is stored
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+
+	// Path of the backup file inside the PersistentVolumeClaim
+	Path string `json:"path,omitempty"`
+
+	// Status of the backup
+	// +kubebuilder:validation:Enum=Succeeded;Failed;Unknown
+	Status DatabaseBackupStatus `json:"status,omitempty"`
+
+	// StartTime records when the backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the backup finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// CredentialsRotationStatus logs the progress of the last rotation of the Events Database credentials
+type CredentialsRotationStatus struct {
+	// Phase of the rotation
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// ObservedRotation is the value of spec.database.rotateCredentials the rotation was run for
+	ObservedRotation int64 `json:"observedRotation,omitempty"`
+
+	// Name of the Job that changes the password in the database
+	Job string `json:"job,omitempty"`
+
+	// LastRotationTime records when the credentials were rotated successfully for the last time
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
+// WorkloadMigrationPhase defines the phases of the migration of the Events Database to a StatefulSet
+type WorkloadMigrationPhase string
+
+// WorkloadMigrationPhases defined here
+const (
+	WorkloadMigrationPhaseScalingDown WorkloadMigrationPhase = "ScalingDown"
+	WorkloadMigrationPhaseCopyingData WorkloadMigrationPhase = "CopyingData"
+	WorkloadMigrationPhaseSwitching   WorkloadMigrationPhase = "Switching"
+	WorkloadMigrationPhaseSucceeded   WorkloadMigrationPhase = "Succeeded"
+	WorkloadMigrationPhaseFailed      WorkloadMigrationPhase = "Failed"
+)
+
+// WorkloadMigrationStatus logs the progress of the migration of the Events Database from a Deployment to a StatefulSet
+type WorkloadMigrationStatus struct {
+	// Phase of the migration
+	// +kubebuilder:validation:Enum=ScalingDown;CopyingData;Switching;Succeeded;Failed
+	Phase WorkloadMigrationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the Job that copies the data
+	Job string `json:"job,omitempty"`
+
+	// StartTime records when the migration started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the migration finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DatabaseUpgradePhase defines the phases of the upgrade of the Events Database to a new major version
+type DatabaseUpgradePhase string
+
+// DatabaseUpgradePhases defined here
+const (
+	DatabaseUpgradePhaseScalingDown          DatabaseUpgradePhase = "ScalingDown"
+	DatabaseUpgradePhaseRestoring            DatabaseUpgradePhase = "Restoring"
+	DatabaseUpgradePhaseSwitching            DatabaseUpgradePhase = "Switching"
+	DatabaseUpgradePhaseAwaitingConfirmation DatabaseUpgradePhase = "AwaitingConfirmation"
+	DatabaseUpgradePhaseSucceeded            DatabaseUpgradePhase = "Succeeded"
+	DatabaseUpgradePhaseRolledBack           DatabaseUpgradePhase = "RolledBack"
+	DatabaseUpgradePhaseFailed               DatabaseUpgradePhase = "Failed"
+)
+
+// DatabaseUpgradeStatus logs the progress of the upgrade of the Events Database to a new major version
+type DatabaseUpgradeStatus struct {
+	// FromVersion is the major version the database ran before the upgrade
+	FromVersion string `json:"fromVersion"`
+
+	// ToVersion is the major version the database is upgraded to
+	ToVersion string `json:"toVersion"`
+
+	// Phase of the upgrade
+	// +kubebuilder:validation:Enum=ScalingDown;Restoring;Switching;AwaitingConfirmation;Succeeded;RolledBack;Failed
+	Phase DatabaseUpgradePhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the Job that dumps the database into the new volume
+	Job string `json:"job,omitempty"`
+
+	// PersistentVolumeClaim the upgraded database keeps its data in
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+
+	// PreviousPersistentVolumeClaim is the volume of the database before the upgrade, it's deleted once the upgrade
+	// is confirmed and it's used again if the upgrade is rolled back
+	PreviousPersistentVolumeClaim string `json:"previousPersistentVolumeClaim,omitempty"`
+
+	// EventsReplicas records the replicas of the Events Deployment before it was scaled down for the upgrade
+	EventsReplicas *int32 `json:"eventsReplicas,omitempty"`
+
+	// StartTime records when the upgrade started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the upgrade finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DatabaseRole defines the roles of the members of the Events Database
+type DatabaseRole string
+
+// DatabaseRoles defined here
+const (
+	DatabaseRolePrimary DatabaseRole = "Primary"
+	DatabaseRoleReplica DatabaseRole = "Replica"
+)
+
+// DatabaseMemberStatus defines the observed state of a pod of the Events Database
+type DatabaseMemberStatus struct {
+	// Pod name
+	Pod string `json:"pod"`
+
+	// Role of the pod
+	// +kubebuilder:validation:Enum=Primary;Replica
+	Role DatabaseRole `json:"role,omitempty"`
+
+	// Ready flags if the pod is ready to serve
+	Ready bool `json:"ready"`
+
+	// LagBytes is the amount of WAL the replica still has to replay to catch up with the primary
+	LagBytes *int64 `json:"lagBytes,omitempty"`
+
+	// A human readable message, i.e. why the lag couldn't be read
+	Message string `json:"message,omitempty"`
+}
+
+// MigrationDryRunStatus defines the outcome of running the pending update scripts in a transaction rolled back
+type MigrationDryRunStatus struct {
+	// Phase of the dry run
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// ScriptsChecksum identifies the scripts dry run, a new dry run is done whenever the pending scripts change
+	ScriptsChecksum string `json:"scriptsChecksum,omitempty"`
+
+	// Scripts run, in order
+	Scripts []string `json:"scripts,omitempty"`
+
+	// Notices and warnings raised by the scripts
+	Notices []string `json:"notices,omitempty"`
+
+	// Error that stopped the scripts, empty if they all run with success
+	Error string `json:"error,omitempty"`
+
+	// AffectedTables lists the tables whose rows or definition the scripts changed
+	AffectedTables []string `json:"affectedTables,omitempty"`
+
+	// CompletionTime records when the dry run finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DatabaseRollbackStatus defines the progress of a rollback of the Events Database schema
+type DatabaseRollbackStatus struct {
+	// TargetVersion the schema is brought back to
+	TargetVersion string `json:"targetVersion"`
+
+	// Phase of the rollback
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message, i.e. why the rollback failed
+	Message string `json:"message,omitempty"`
+}
+
+// DatabaseStatus defines the observed state of the Events Database
+type DatabaseStatus struct {
+	// CredentialsRotation shows the progress of the last credentials rotation
+	CredentialsRotation *CredentialsRotationStatus `json:"credentialsRotation,omitempty"`
+
+	// Capacity of the volume bound to the Events Database
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+
+	// Workload the Events Database runs as
+	Workload DatabaseWorkload `json:"workload,omitempty"`
+
+	// WorkloadMigration shows the progress of the migration of the Events Database to a StatefulSet
+	WorkloadMigration *WorkloadMigrationStatus `json:"workloadMigration,omitempty"`
+
+	// Members lists the pods of the Events Database with their role and replication lag
+	Members []DatabaseMemberStatus `json:"members,omitempty"`
+
+	// Primary is the replica pod promoted to primary by the last failover, empty while the original primary serves
+	Primary string `json:"primary,omitempty"`
+
+	// SchemaVersion is the highest version recorded in the operator_version table of the database
+	SchemaVersion string `json:"schemaVersion,omitempty"`
+
+	// PendingScripts lists the update scripts not run yet
+	PendingScripts []string `json:"pendingScripts,omitempty"`
+
+	// DryRun shows the outcome of the last dry run of the pending update scripts
+	DryRun *MigrationDryRunStatus `json:"dryRun,omitempty"`
+
+	// Rollback shows the progress of the last rollback of the schema
+	Rollback *DatabaseRollbackStatus `json:"rollback,omitempty"`
+
+	// SeedData shows if the events table was seeded, it's never seeded again once Succeeded
+	SeedData *SeedDataStatus `json:"seedData,omitempty"`
+
+	// Version is the PostgreSQL major version the Events Database runs
+	Version string `json:"version,omitempty"`
+
+	// Upgrade shows the progress of the last upgrade to a new major version
+	Upgrade *DatabaseUpgradeStatus `json:"upgrade,omitempty"`
+}
+
+// SeedDataStatus defines the observed state of the seeding of the Events Database
+type SeedDataStatus struct {
+	// Source of the events, the sample set or the ConfigMap they were loaded from
+	Source string `json:"source"`
+
+	// Phase of the seeding
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// Events loaded, 0 if the events table wasn't empty
+	Events int32 `json:"events,omitempty"`
+
+	// CompletionTime records when the seeding finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// A human readable message, i.e. why the seeding failed or was skipped
+	Message string `json:"message,omitempty"`
 }
 
 // AppServiceStatus defines the observed state of AppService
@@ -184,6 +801,19 @@ type AppServiceStatus struct {
 	// List of Event Database Scripts Runs
 	EventsDatabaseScriptRuns []DatabaseScriptRun `json:"eventsDatabaseScriptRuns,omitempty"`
 
+	// Last backup of the Events Database taken before running update scripts
+	EventsDatabaseBackup *DatabaseBackup `json:"eventsDatabaseBackup,omitempty"`
+
+	// LastSuccessfulBackupTime records when the last scheduled backup of the Events Database finished successfully
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Last Successful Backup"
+	LastSuccessfulBackupTime *metav1.Time `json:"lastSuccessfulBackupTime,omitempty"`
+
+	// Database shows the observed state of the Events Database
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Database"
+	Database *DatabaseStatus `json:"database,omitempty"`
+
 	// Last Action run
 	// +kubebuilder:validation:Enum=BackupStarted;NoAction;RequeueEvent
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
@@ -0,0 +1,97 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatabaseOperationPhase defines the phases of an operation run against the database of an AppService
+type DatabaseOperationPhase string
+
+// DatabaseOperationPhases defined here
+const (
+	DatabaseOperationPhasePending   DatabaseOperationPhase = "Pending"
+	DatabaseOperationPhaseRunning   DatabaseOperationPhase = "Running"
+	DatabaseOperationPhaseSucceeded DatabaseOperationPhase = "Succeeded"
+	DatabaseOperationPhaseFailed    DatabaseOperationPhase = "Failed"
+)
+
+// AppServiceBackupSpec defines the desired state of AppServiceBackup
+type AppServiceBackupSpec struct {
+	// Name of the AppService, in the same namespace, whose Events Database is backed up
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="AppService Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	AppServiceName string `json:"appServiceName"`
+}
+
+// AppServiceBackupStatus defines the observed state of AppServiceBackup
+type AppServiceBackupStatus struct {
+	// Phase of the backup
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Phase"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the Job that takes the backup
+	Job string `json:"job,omitempty"`
+
+	// PersistentVolumeClaim where the backup is stored
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+
+	// Path of the backup file inside the PersistentVolumeClaim
+	Path string `json:"path,omitempty"`
+
+	// StartTime records when the backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the backup finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AppService",type=string,JSONPath=`.spec.appServiceName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.status.path`
+
+// AppServiceBackup is the Schema for the appservicebackups API
+type AppServiceBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AppServiceBackupSpec   `json:"spec,omitempty"`
+	Status AppServiceBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppServiceBackupList contains a list of AppServiceBackup
+type AppServiceBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppServiceBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppServiceBackup{}, &AppServiceBackupList{})
+}
@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DataFormat defines the format of the events exported from or imported into the Events Database
+type DataFormat string
+
+// DataFormats defined here
+const (
+	DataFormatJSON DataFormat = "JSON"
+	DataFormatCSV  DataFormat = "CSV"
+)
+
+// DataLocation defines where exported events are stored, one of ConfigMap or PersistentVolumeClaim
+type DataLocation struct {
+	// ConfigMap holding the events in the key events.json or events.csv, depending on the format. ConfigMaps can't
+	// hold more than 1MiB, larger catalogs should go to a PersistentVolumeClaim
+	// +optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+
+	// PersistentVolumeClaim holding the events in the file set in Path
+	// +optional
+	PersistentVolumeClaim *corev1.LocalObjectReference `json:"persistentVolumeClaim,omitempty"`
+
+	// Path of the file from the root of the PersistentVolumeClaim, it can't leave it. Exports default to the name of the AppServiceDataExport plus the extension of the format. CSV files are imported only if every column of the header is a column of the events table
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// AppServiceDataExportSpec defines the desired state of AppServiceDataExport
+type AppServiceDataExportSpec struct {
+	// Name of the AppService, in the same namespace, whose events are exported
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="AppService Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	AppServiceName string `json:"appServiceName"`
+
+	// Format of the export, JSON by default
+	// +kubebuilder:validation:Enum=JSON;CSV
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Format"
+	// +optional
+	Format DataFormat `json:"format,omitempty"`
+
+	// Destination of the export
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Destination"
+	Destination DataLocation `json:"destination"`
+}
+
+// AppServiceDataExportStatus defines the observed state of AppServiceDataExport
+type AppServiceDataExportStatus struct {
+	// Phase of the export
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Phase"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Format of the exported events
+	Format DataFormat `json:"format,omitempty"`
+
+	// Location of the exported events, paths are absolute
+	Location *DataLocation `json:"location,omitempty"`
+
+	// Events exported, only known for exports to a ConfigMap
+	Events int32 `json:"events,omitempty"`
+
+	// Name of the Job that writes the export into a PersistentVolumeClaim
+	Job string `json:"job,omitempty"`
+
+	// StartTime records when the export started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the export finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AppService",type=string,JSONPath=`.spec.appServiceName`
+// +kubebuilder:printcolumn:name="Format",type=string,JSONPath=`.status.format`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Events",type=integer,JSONPath=`.status.events`
+
+// AppServiceDataExport is the Schema for the appservicedataexports API
+type AppServiceDataExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AppServiceDataExportSpec   `json:"spec,omitempty"`
+	Status AppServiceDataExportStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppServiceDataExportList contains a list of AppServiceDataExport
+type AppServiceDataExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppServiceDataExport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppServiceDataExport{}, &AppServiceDataExportList{})
+}
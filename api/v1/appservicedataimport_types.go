@@ -0,0 +1,108 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AppServiceDataImportSpec defines the desired state of AppServiceDataImport
+type AppServiceDataImportSpec struct {
+	// Name of the AppService, in the same namespace, whose events are updated
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="AppService Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	AppServiceName string `json:"appServiceName"`
+
+	// Name of an AppServiceDataExport, in the same namespace, whose events are imported. Either ExportName or Source
+	// has to be set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Export Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	// +optional
+	ExportName string `json:"exportName,omitempty"`
+
+	// Format of the events in Source, JSON by default. Ignored if ExportName is set
+	// +kubebuilder:validation:Enum=JSON;CSV
+	// +optional
+	Format DataFormat `json:"format,omitempty"`
+
+	// Source of the events, an export copied from another cluster for instance. Path is required for a
+	// PersistentVolumeClaim
+	// +optional
+	Source *DataLocation `json:"source,omitempty"`
+}
+
+// AppServiceDataImportStatus defines the observed state of AppServiceDataImport
+type AppServiceDataImportStatus struct {
+	// Phase of the import
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Phase"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Format of the imported events
+	Format DataFormat `json:"format,omitempty"`
+
+	// Location the events are imported from, paths are absolute
+	Location *DataLocation `json:"location,omitempty"`
+
+	// Events imported, only known for imports from a ConfigMap
+	Events int32 `json:"events,omitempty"`
+
+	// Name of the Job that imports the events from a PersistentVolumeClaim
+	Job string `json:"job,omitempty"`
+
+	// StartTime records when the import started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the import finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AppService",type=string,JSONPath=`.spec.appServiceName`
+// +kubebuilder:printcolumn:name="Format",type=string,JSONPath=`.status.format`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Events",type=integer,JSONPath=`.status.events`
+
+// AppServiceDataImport is the Schema for the appservicedataimports API
+type AppServiceDataImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AppServiceDataImportSpec   `json:"spec,omitempty"`
+	Status AppServiceDataImportStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppServiceDataImportList contains a list of AppServiceDataImport
+type AppServiceDataImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppServiceDataImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppServiceDataImport{}, &AppServiceDataImportList{})
+}
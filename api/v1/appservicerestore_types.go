@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AppServiceRestoreSpec defines the desired state of AppServiceRestore
+type AppServiceRestoreSpec struct {
+	// Name of the AppService, in the same namespace, whose Events Database is restored
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="AppService Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	AppServiceName string `json:"appServiceName"`
+
+	// Name of the AppServiceBackup to restore, it has to be Succeeded. Backups are kept in the backups
+	// PersistentVolumeClaim of their namespace, so only backups in the same namespace can be restored, events are
+	// copied to other namespaces with AppServiceDataExport and AppServiceDataImport
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Backup Name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	BackupName string `json:"backupName,omitempty"`
+
+	// Path of the backup file in the backups PersistentVolumeClaim, used when BackupName is not set. It's relative to
+	// the PersistentVolumeClaim, or absolute under /backup where it's mounted. Scheduled backups are found under
+	// scheduled/, where their own PersistentVolumeClaim is mounted
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Path"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	Path string `json:"path,omitempty"`
+}
+
+// AppServiceRestoreStatus defines the observed state of AppServiceRestore
+type AppServiceRestoreStatus struct {
+	// Phase of the restore
+	// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Phase"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	Phase DatabaseOperationPhase `json:"phase,omitempty"`
+
+	// A human readable message about the current phase
+	Message string `json:"message,omitempty"`
+
+	// Name of the Job that restores the backup
+	Job string `json:"job,omitempty"`
+
+	// Path of the backup file restored
+	Path string `json:"path,omitempty"`
+
+	// Replicas the Events Deployment had before being scaled down for the restore
+	EventsReplicas *int32 `json:"eventsReplicas,omitempty"`
+
+	// StartTime records when the restore started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime records when the restore finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="AppService",type=string,JSONPath=`.spec.appServiceName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.status.path`
+
+// AppServiceRestore is the Schema for the appservicerestores API
+type AppServiceRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AppServiceRestoreSpec   `json:"spec,omitempty"`
+	Status AppServiceRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppServiceRestoreList contains a list of AppServiceRestore
+type AppServiceRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppServiceRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppServiceRestore{}, &AppServiceRestoreList{})
+}
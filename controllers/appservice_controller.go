@@ -17,7 +17,6 @@ limitations under the License.
 package controllers
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"math"
@@ -26,24 +25,24 @@ import (
 	"time"
 
 	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	"github.com/atarazana/gramola-operator/database"
 	_deployment "github.com/atarazana/gramola-operator/deployment"
 
+	"github.com/blang/semver"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/log"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/tools/remotecommand"
-	"k8s.io/kubectl/pkg/scheme"
-
-	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -61,6 +60,9 @@ const (
 	errorAlias                    = "Not a proper AppService object because Alias is not valid"
 	errorPlatform                 = "Not a proper AppService object because Platform is not valid"
 	errorDomainName               = "DomainName is not valid"
+	errorVolumeMode               = "Events Database storage must be a Filesystem volume for PostgreSQL to use it"
+	errorExternalReplicas         = "Events Database replicas can't be set for an external database"
+	errorPromotedReplicas         = "Events Database replicas can't be removed, one of them was promoted to primary"
 	errorNotAppServiceObject      = "Not a AppService object"
 	errorAppServiceObjectNotValid = "Not a valid AppService object"
 	errorUnableToUpdateInstance   = "Unable to update instance"
@@ -68,6 +70,9 @@ const (
 	errorUnexpected               = "Unexpected error"
 )
 
+// eventsDatabaseScriptTimeout is how long an update or down script may take before it's rolled back
+const eventsDatabaseScriptTimeout = 10 * time.Minute
+
 //var log = logf.Log.WithName(controllerName)
 
 // AppServiceReconciler reconciles a AppService object
@@ -83,9 +88,11 @@ type AppServiceReconciler struct {
 // +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices,verbs=*
 // +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices/finalizers,verbs=*
 // +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups="",resources=pods;pods/exec;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets;serviceaccounts,verbs=create;delete;get;list;patch;update;watch
+// +kubebuilder:rbac:groups="",resources=pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets;serviceaccounts,verbs=create;delete;get;list;patch;update;watch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=create;delete;get;list;patch;update;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments;deployments/finalizers;daemonsets;replicasets;statefulsets,verbs=create;delete;get;list;patch;update;watch
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;create
 // +kubebuilder:rbac:groups=extensions,resources=ingresses,verbs=*
 // +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=*
@@ -111,7 +118,7 @@ func (r *AppServiceReconciler) Reconcile(request ctrl.Request) (ctrl.Result, err
 		return reconcile.Result{}, err
 	}
 
-	log.Info(fmt.Sprintf("Status %s", instance.Status))
+	log.Info(fmt.Sprintf("Status %v", instance.Status))
 
 	// Validate the CR instance
 	if ok, err := r.isValid(instance); !ok {
@@ -132,6 +139,13 @@ func (r *AppServiceReconciler) Reconcile(request ctrl.Request) (ctrl.Result, err
 		}
 	}
 
+	//////////////////////////
+	// Events Database pooler
+	//////////////////////////
+	if _, err := r.reconcilePooler(instance); err != nil {
+		return r.ManageError(instance, err)
+	}
+
 	//////////////////////////
 	// Events
 	//////////////////////////
@@ -139,6 +153,23 @@ func (r *AppServiceReconciler) Reconcile(request ctrl.Request) (ctrl.Result, err
 		return r.ManageError(instance, err)
 	}
 
+	//////////////////////////
+	// Events Database replicas
+	//////////////////////////
+	if _, err := r.reconcileReplicas(instance); err != nil {
+		return r.ManageError(instance, err)
+	}
+
+	//////////////////////////
+	// Events Database failover
+	//////////////////////////
+	if failingOver, err := r.reconcileFailover(instance); err != nil {
+		return r.ManageError(instance, err)
+	} else if failingOver {
+		log.Info(fmt.Sprintf("Requeueing event as the events database failover hasn't finished yet"))
+		return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
+	}
+
 	//////////////////////////
 	// Gateway
 	//////////////////////////
@@ -153,41 +184,149 @@ func (r *AppServiceReconciler) Reconcile(request ctrl.Request) (ctrl.Result, err
 		return r.ManageError(instance, err)
 	}
 
+	//////////////////////////
+	// Scheduled Backups
+	//////////////////////////
+	if _, err := r.reconcileBackup(instance); err != nil {
+		return r.ManageError(instance, err)
+	}
+
+	//////////////////////////
+	// Rotate Events Database credentials
+	//////////////////////////
+	if rotating, err := r.reconcileCredentialsRotation(instance); err != nil {
+		return r.ManageError(instance, err)
+	} else if rotating {
+		log.Info(fmt.Sprintf("Requeueing event as the events database credentials rotation hasn't finished yet"))
+		return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
+	}
+
+	//////////////////////////
+	// Upgrade Events Database version
+	//////////////////////////
+	if upgrading, err := r.reconcileEventsDatabaseVersion(instance); err != nil {
+		return r.ManageError(instance, err)
+	} else if upgrading {
+		log.Info(fmt.Sprintf("Requeueing event as the events database upgrade hasn't finished yet"))
+		return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
+	}
+
 	//////////////////////////
 	// Update Events DataBase
 	//////////////////////////
-	// Update only if not applied before with success
-	if !r.CurrentDatabaseScriptWasRun(instance) {
-		// TODO Backup DB
-
-		// Start the Script Run
-		scriptRun := &gramolav1.DatabaseScriptRun{
-			Script: _deployment.EventsDatabaseUpdateScriptName,
-			Status: gramolav1.DatabaseUpdateStatusUnknown,
+	// Run in order every update script not applied before with success
+	updateScripts, err := r.getEventsDatabaseUpdateScripts(instance)
+	if err != nil {
+		return r.ManageError(instance, err)
+	}
+	credentials, err := r.getEventsDatabaseCredentials(instance)
+	if err != nil {
+		return r.ManageError(instance, err)
+	}
+	checksums, err := _deployment.GetEventsDatabaseUpdateScriptChecksums(updateScripts, _deployment.NewEventsDatabaseScriptContext(instance, credentials))
+	if err != nil {
+		return r.ManageError(instance, err)
+	}
+	// The database knows better which scripts were applied
+	if err := r.reconcileSchemaVersion(instance, updateScripts, credentials); err != nil {
+		return r.ManageError(instance, err)
+	}
+	// Scripts already applied must not have changed
+	if err := r.reconcileMigrationDrift(instance, checksums); err != nil {
+		return r.ManageError(instance, err)
+	}
+	pendingScripts := r.PendingDatabaseScripts(instance, updateScripts)
+	// Undo the scripts above the rollback target, those are held until the target is removed
+	if instance.Spec.Database != nil && len(instance.Spec.Database.RollbackTo) > 0 {
+		if rolledBack, err := r.rollbackEventsDatabase(instance, updateScripts, instance.Spec.Database.RollbackTo); err != nil {
+			return r.ManageError(instance, err)
+		} else if !rolledBack {
+			log.Info(fmt.Sprintf("Requeueing event as the events database rollback hasn't finished yet"))
+			return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
 		}
-		if dataBaseUpdated, err := r.UpdateEventsDatabase(request); err != nil {
-			log.Error(err, "Error DB update", "instance", instance)
-			// Update Status
-			scriptRun.Status = gramolav1.DatabaseUpdateStatusFailed
-			instance.Status.EventsDatabaseScriptRuns = append(instance.Status.EventsDatabaseScriptRuns, *scriptRun)
-			instance.Status.EventsDatabaseUpdated = gramolav1.DatabaseUpdateStatusFailed
+		pendingScripts = databaseScriptsUpTo(r.PendingDatabaseScripts(instance, updateScripts), instance.Spec.Database.RollbackTo)
+	}
+	setPendingDatabaseScripts(instance, pendingScripts)
+	switch _deployment.GetMigrationMode(instance) {
+	case gramolav1.MigrationModeDryRun:
+		// Pending scripts are only tried out
+		if len(pendingScripts) > 0 {
+			if done, err := r.dryRunEventsDatabaseScripts(instance, pendingScripts, credentials, checksums); err != nil {
+				return r.ManageError(instance, err)
+			} else if !done {
+				log.Info(fmt.Sprintf("Requeueing event as the events database dry run hasn't finished yet"))
+				return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
+			}
+		}
+		pendingScripts = nil
+	case gramolav1.MigrationModeManual:
+		// Pending scripts wait for approval
+		if pendingScripts, err = approvedDatabaseScripts(instance, pendingScripts); err != nil {
 			return r.ManageError(instance, err)
-		} else {
-			if !dataBaseUpdated {
-				// Maybe the Database Pods weren't ready but running... so scchedule a new reconcile cycle
-				log.Info(fmt.Sprintf("Requeueing event as wwe couldn't update the events database"))
+		}
+	}
+	if len(pendingScripts) > 0 {
+		// Backup the database before running the first pending script, update only if the backup succeeded. A database
+		// no script has been run against yet is empty, so there's nothing to back up
+		if r.AnyDatabaseScriptWasRun(instance) {
+			if backedUp, err := r.BackupEventsDatabase(instance, pendingScripts[0].TrackedVersion()); err != nil {
+				log.Error(err, "Error DB backup", "instance", instance)
+				return r.ManageError(instance, err)
+			} else if !backedUp {
+				log.Info(fmt.Sprintf("Requeueing event as the events database backup hasn't finished yet"))
+				return r.ManageSuccess(instance, 10*time.Second, gramolav1.BackupStarted)
+			}
+		}
+
+		for _, updateScript := range pendingScripts {
+			// Run the Script and record the run
+			scriptRun, err := r.UpdateEventsDatabase(instance, updateScript, credentials, checksums[updateScript.Name])
+			if scriptRun != nil {
+				r.SetDatabaseScriptRun(instance, scriptRun)
+			}
+			if err != nil {
+				log.Error(err, "Error DB update", "instance", instance, "script", updateScript.Name)
+				// Update Status
+				instance.Status.EventsDatabaseUpdated = gramolav1.DatabaseUpdateStatusFailed
+				return r.ManageError(instance, err)
+			}
+			if scriptRun == nil || scriptRun.Status != gramolav1.DatabaseUpdateStatusSucceeded {
+				// Maybe the Database Pods weren't ready... so scchedule a new reconcile cycle
+				log.Info(fmt.Sprintf("Requeueing event as the events database is not updated yet with %s", updateScript.Name))
 				return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
 			}
 
+			// A later rollback of this version has to be backed up again
+			if err := r.deleteJobs(instance, _deployment.EventsDatabasePreUpdateBackupJobNameFor(eventsDatabaseRollbackBackupVersion(updateScript.TrackedVersion()))); err != nil {
+				return r.ManageError(instance, err)
+			}
+
 			// Update Status
-			scriptRun.Status = gramolav1.DatabaseUpdateStatusSucceeded
-			instance.Status.EventsDatabaseScriptRuns = append(instance.Status.EventsDatabaseScriptRuns, *scriptRun)
 			instance.Status.EventsDatabaseUpdated = gramolav1.DatabaseUpdateStatusSucceeded
+			setPendingDatabaseScripts(instance, r.PendingDatabaseScripts(instance, updateScripts))
+			if len(updateScript.ConfigMap) <= 0 {
+				instance.Status.Database.SchemaVersion = updateScript.Version.String()
+			}
 
-			log.Info(fmt.Sprintf("Database UpdateStatus Succeeded ====> %s", instance.Status))
+			log.Info(fmt.Sprintf("Database UpdateStatus Succeeded for %s ====> %v", updateScript.Name, instance.Status))
 		}
 	}
 
+	//////////////////////////
+	// Seed Events Database
+	//////////////////////////
+	if seeding, err := r.reconcileSeedData(instance, updateScripts, credentials); err != nil {
+		return r.ManageError(instance, err)
+	} else if seeding {
+		log.Info(fmt.Sprintf("Requeueing event as the events database hasn't been seeded yet"))
+		return r.ManageSuccess(instance, 10*time.Second, gramolav1.RequeueEvent)
+	}
+
+	// Keep the replication lag of the replicas up to date
+	if _deployment.IsEventsDatabaseReplicated(instance) {
+		return r.ManageSuccess(instance, 30*time.Second, gramolav1.NoAction)
+	}
+
 	// Nothing else to do
 	return r.ManageSuccess(instance, 0, gramolav1.NoAction)
 }
@@ -218,6 +357,28 @@ func (r *AppServiceReconciler) isValid(obj metav1.Object) (bool, error) {
 		return false, err
 	}
 
+	// Check the Events Database volume can be mounted
+	if instance.Spec.Database != nil && instance.Spec.Database.Storage != nil && instance.Spec.Database.Storage.VolumeMode != nil &&
+		*instance.Spec.Database.Storage.VolumeMode != corev1.PersistentVolumeFilesystem {
+		err := k8s_errors.NewBadRequest(errorVolumeMode)
+		log.Error(err, errorVolumeMode)
+		return false, err
+	}
+
+	// Check replicas are only requested for a database run by the operator
+	if instance.Spec.Database != nil && instance.Spec.Database.Replicas > 0 && instance.Spec.Database.External != nil {
+		err := k8s_errors.NewBadRequest(errorExternalReplicas)
+		log.Error(err, errorExternalReplicas)
+		return false, err
+	}
+
+	// Check the promoted primary isn't removed along with the replicas
+	if len(_deployment.GetEventsDatabasePromotedPrimary(instance)) > 0 && (instance.Spec.Database == nil || instance.Spec.Database.Replicas <= 0) {
+		err := k8s_errors.NewBadRequest(errorPromotedReplicas)
+		log.Error(err, errorPromotedReplicas)
+		return false, err
+	}
+
 	// Check DomainName if platform is kubernetes
 	if instance.Spec.Platform == gramolav1.PlatformKubernetes {
 		if matched, err := regexp.MatchString(gramolav1.DomainNameRegex, instance.Spec.DomainName); !matched || err != nil {
@@ -350,49 +511,175 @@ func (r *AppServiceReconciler) ManageSuccess(obj metav1.Object, requeueAfter tim
 	return reconcile.Result{}, nil
 }
 
-// UpdateEventsDatabase runs a script in the first 'Events' database pod found (and ready) returns true if the script was run succesfully
-func (r *AppServiceReconciler) UpdateEventsDatabase(request reconcile.Request) (bool, error) {
+// UpdateEventsDatabase runs an update script in a single transaction against the 'Events' database once it is ready,
+// returns the run of the script or nil if the database wasn't ready yet or someone else is migrating the same version
+func (r *AppServiceReconciler) UpdateEventsDatabase(instance *gramolav1.AppService, updateScript _deployment.EventsDatabaseUpdateScript, credentials map[string]string, checksum string) (*gramolav1.DatabaseScriptRun, error) {
+	if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+		return nil, err
+	}
+	script, err := _deployment.RenderEventsDatabaseUpdateScript(updateScript, _deployment.NewEventsDatabaseScriptContext(instance, credentials))
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := metav1.Now()
+	scriptRun := &gramolav1.DatabaseScriptRun{
+		Script:    updateScript.Name,
+		Version:   updateScript.TrackedVersion(),
+		Checksum:  checksum,
+		Status:    gramolav1.DatabaseUpdateStatusUnknown,
+		StartTime: &startTime,
+	}
+
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	log.Info(fmt.Sprintf("Running %s on %s", updateScript.Name, _deployment.EventsDatabaseServiceName))
+	ctx, cancel := context.WithTimeout(context.TODO(), eventsDatabaseScriptTimeout)
+	defer cancel()
+	err = db.RunUpdateScript(ctx, updateScript.Name, script, scriptRun.Version, checksum)
+
+	if err == database.ErrMigrationLocked {
+		log.Info(fmt.Sprintf("Waiting to run %s: %s", updateScript.Name, err))
+		return nil, nil
+	}
+
+	completionTime := metav1.Now()
+	scriptRun.CompletionTime = &completionTime
+	scriptRun.Duration = &metav1.Duration{Duration: completionTime.Sub(startTime.Time)}
+	if err != nil {
+		scriptRun.Status = gramolav1.DatabaseUpdateStatusFailed
+		scriptRun.Error = newDatabaseScriptError(err)
+		return scriptRun, errors.Errorf("Failed running script %s on %s: %s", updateScript.Name, _deployment.EventsDatabaseServiceName, err)
+	}
+
+	r.Recorder.Eventf(instance, "Normal", "Script Run", "Ran %s on %s", updateScript.Name, _deployment.EventsDatabaseServiceName)
+	scriptRun.Status = gramolav1.DatabaseUpdateStatusSucceeded
+	return scriptRun, nil
+}
+
+// BackupEventsDatabase dumps the 'Events' database in a Job before updating it to the given version, the backup
+// is recorded in the status and returns true once the backup has finished with success
+func (r *AppServiceReconciler) BackupEventsDatabase(instance *gramolav1.AppService, version string) (bool, error) {
+	jobName := _deployment.EventsDatabasePreUpdateBackupJobNameFor(version)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: instance.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+
+		// Create the Job only if there's a database ready to be dumped
+		if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+			return false, err
+		}
+		if job, err = _deployment.NewEventsDatabasePreUpdateBackupJob(instance, r.Scheme, jobName); err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(instance, "Normal", "Backup Started", "Created %s Job to backup %s", job.Name, _deployment.EventsDatabaseServiceName)
+	}
+
+	backup := &gramolav1.DatabaseBackup{
+		Job:                   job.Name,
+		PersistentVolumeClaim: _deployment.EventsDatabaseBackupPersistentVolumeClaimName,
+		Path:                  _deployment.EventsDatabaseBackupPathFor(job.Name),
+		Status:                gramolav1.DatabaseBackupStatusUnknown,
+		StartTime:             job.Status.StartTime,
+		CompletionTime:        job.Status.CompletionTime,
+	}
+	instance.Status.EventsDatabaseBackup = backup
+
+	if job.Status.Succeeded > 0 {
+		backup.Status = gramolav1.DatabaseBackupStatusSucceeded
+		return true, nil
+	}
+
+	if condition := getJobFailedCondition(job); condition != nil {
+		completionTime := condition.LastTransitionTime
+		backup.CompletionTime = &completionTime
+		backup.Status = gramolav1.DatabaseBackupStatusFailed
+		return false, errors.Errorf("Job %s failed to backup %s: %s", job.Name, _deployment.EventsDatabaseServiceName, condition.Message)
+	}
+
+	// Job still running
+	return false, nil
+}
+
+// getJobFailedCondition returns the condition that flags the Job as failed or nil if it hasn't failed
+func getJobFailedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if job.Status.Conditions[i].Type == batchv1.JobFailed && job.Status.Conditions[i].Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// setCondition adds or updates the condition of the given type, the transition time only changes along with the status
+func setCondition(instance *gramolav1.AppService, conditionType gramolav1.AppServiceConditionType, status gramolav1.AppServiceConditionStatus, reason gramolav1.AppServiceConditionReason, message string) {
+	condition := gramolav1.AppServiceCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i := range instance.Status.Conditions {
+		if instance.Status.Conditions[i].Type == conditionType {
+			if instance.Status.Conditions[i].Status == status {
+				condition.LastTransitionTime = instance.Status.Conditions[i].LastTransitionTime
+			}
+			instance.Status.Conditions[i] = condition
+			return
+		}
+	}
+	instance.Status.Conditions = append(instance.Status.Conditions, condition)
+}
+
+// IsEventsDatabaseReady returns true if there is at least one 'Events' database pod running and ready and the
+// database answers through its Service, external databases only have to answer
+func (r *AppServiceReconciler) IsEventsDatabaseReady(instance *gramolav1.AppService) (bool, error) {
+	if _deployment.IsEventsDatabaseExternal(instance) {
+		return r.isEventsDatabaseReachable(instance), nil
+	}
+
+	// After a failover the promoted replica is the database
+	if primary := _deployment.GetEventsDatabasePromotedPrimary(instance); len(primary) > 0 {
+		pod := &corev1.Pod{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: primary, Namespace: instance.Namespace}, pod); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return isPodReady(pod) && r.isEventsDatabaseReachable(instance), nil
+	}
+
 	// List all pods of the Events Database
 	podList := &corev1.PodList{}
 	lbs := map[string]string{
 		"component": _deployment.EventsDatabaseServiceName,
 	}
 	labelSelector := labels.SelectorFromSet(lbs)
-	listOps := &client.ListOptions{Namespace: request.Namespace, LabelSelector: labelSelector}
+	listOps := &client.ListOptions{Namespace: instance.Namespace, LabelSelector: labelSelector}
 	if err := r.Client.List(context.TODO(), podList, listOps); err != nil {
 		return false, err
 	}
 
-	// Count the pods that are pending or running as available
-	var ready []corev1.Pod
 	for _, pod := range podList.Items {
 		log.Info(fmt.Sprintf("pod: %s phase: %s statuses: %v", pod.Name, pod.Status.Phase, pod.Status.ContainerStatuses))
 		if pod.Status.Phase == corev1.PodRunning {
 			for _, containerStatus := range pod.Status.ContainerStatuses {
 				if containerStatus.Name == _deployment.EventsDatabaseServiceContainerName && containerStatus.Ready {
-					ready = append(ready, pod)
-					break
-				}
-			}
-		}
-	}
-
-	log.Info(fmt.Sprintf("ready: %v", ready))
-
-	if len(ready) > 0 {
-		filePath := _deployment.EventsDatabaseScriptsMountPath + "/" + _deployment.EventsDatabaseUpdateScriptName
-		if _out, _err, err := r.ExecuteRemoteCommand(&ready[0], "psql -U $POSTGRESQL_USER $POSTGRESQL_DATABASE -f "+filePath); err != nil {
-			return false, err
-		} else {
-			log.Info(fmt.Sprintf("stdout: %s\nstderr: %s", _out, _err))
-			if len(_err) > 0 {
-				return false, errors.Wrapf(err, "Failed before executing script %s on %s", filePath, _deployment.EventsDatabaseServiceName)
-			} else {
-				errorFound := regexp.MustCompile(`(?i)error`)
-				if errorFound.MatchString(_out) {
-					return false, errors.Wrapf(err, "Failed executing script %s on %s", filePath, _deployment.EventsDatabaseServiceName)
+					return r.isEventsDatabaseReachable(instance), nil
 				}
-				return true, nil
 			}
 		}
 	}
@@ -400,53 +687,45 @@ func (r *AppServiceReconciler) UpdateEventsDatabase(request reconcile.Request) (
 	return false, nil
 }
 
-// ExecuteRemoteCommand executes a remote shell command on the given pod
-// returns the output from stdout and stderr
-func (r *AppServiceReconciler) ExecuteRemoteCommand(pod *corev1.Pod, command string) (string, string, error) {
-	kubeCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(),
-		&clientcmd.ConfigOverrides{},
-	)
-	restCfg, err := kubeCfg.ClientConfig()
+// isEventsDatabaseReachable returns true if the 'Events' database takes connections and answers queries
+func (r *AppServiceReconciler) isEventsDatabaseReachable(instance *gramolav1.AppService) bool {
+	credentials, err := r.getEventsDatabaseCredentials(instance)
 	if err != nil {
-		return "", "", err
+		log.Info(fmt.Sprintf("%s not reachable: %s", _deployment.EventsDatabaseServiceName, err))
+		return false
 	}
-	coreClient, err := corev1client.NewForConfig(restCfg)
+	db, err := connectEventsDatabase(instance, "", credentials)
 	if err != nil {
-		return "", "", err
-	}
-
-	buf := &bytes.Buffer{}
-	errBuf := &bytes.Buffer{}
-	request := coreClient.RESTClient().
-		Post().
-		Namespace(pod.Namespace).
-		Resource("pods").
-		Name(pod.Name).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Command: []string{"/bin/bash", "-c", command},
-			Stdin:   false,
-			Stdout:  true,
-			Stderr:  true,
-			TTY:     true,
-		}, scheme.ParameterCodec)
-	exec, err := remotecommand.NewSPDYExecutor(restCfg, "POST", request.URL())
-	err = exec.Stream(remotecommand.StreamOptions{
-		Stdout: buf,
-		Stderr: errBuf,
-	})
-	if err != nil {
-		return "", "", errors.Wrapf(err, "Failed executing command %s on %v/%v", command, pod.Namespace, pod.Name)
+		log.Info(fmt.Sprintf("%s not reachable: %s", _deployment.EventsDatabaseServiceName, err))
+		return false
 	}
+	db.Close()
 
-	return buf.String(), errBuf.String(), nil
+	return true
 }
 
-// CurrentDatabaseScriptWasRun checks if the current Database Update Script was run
-func (r *AppServiceReconciler) CurrentDatabaseScriptWasRun(instance *gramolav1.AppService) bool {
+// SetDatabaseScriptRun updates the last run of the script if it's the same run, still in progress or failed, otherwise adds it as a new run
+func (r *AppServiceReconciler) SetDatabaseScriptRun(instance *gramolav1.AppService, scriptRun *gramolav1.DatabaseScriptRun) {
+	for i := len(instance.Status.EventsDatabaseScriptRuns) - 1; i >= 0; i-- {
+		if instance.Status.EventsDatabaseScriptRuns[i].Script == scriptRun.Script {
+			// A retry of a failed run takes its place
+			if instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusUnknown ||
+				instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusFailed ||
+				instance.Status.EventsDatabaseScriptRuns[i].StartTime.Equal(scriptRun.StartTime) {
+				instance.Status.EventsDatabaseScriptRuns[i] = *scriptRun
+				return
+			}
+			break
+		}
+	}
+
+	instance.Status.EventsDatabaseScriptRuns = append(instance.Status.EventsDatabaseScriptRuns, *scriptRun)
+}
+
+// DatabaseScriptWasRun checks if the given Database Update Script was run with success
+func (r *AppServiceReconciler) DatabaseScriptWasRun(instance *gramolav1.AppService, scriptName string) bool {
 	for i := range instance.Status.EventsDatabaseScriptRuns {
-		if instance.Status.EventsDatabaseScriptRuns[i].Script == _deployment.EventsDatabaseUpdateScriptName &&
+		if instance.Status.EventsDatabaseScriptRuns[i].Script == scriptName &&
 			instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusSucceeded {
 			return true
 		}
@@ -455,6 +734,44 @@ func (r *AppServiceReconciler) CurrentDatabaseScriptWasRun(instance *gramolav1.A
 	return false
 }
 
+// AnyDatabaseScriptWasRun checks if any Database Update Script was run with success, even if it was rolled back later
+func (r *AppServiceReconciler) AnyDatabaseScriptWasRun(instance *gramolav1.AppService) bool {
+	for i := range instance.Status.EventsDatabaseScriptRuns {
+		if instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusSucceeded ||
+			instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusRolledBack {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PendingDatabaseScripts returns, keeping the order, the update scripts that haven't been run with success yet. Built-in
+// scripts at or below the highest version run with success are applied too, installs that predate a script only
+// recorded the last one they ran
+func (r *AppServiceReconciler) PendingDatabaseScripts(instance *gramolav1.AppService, updateScripts []_deployment.EventsDatabaseUpdateScript) []_deployment.EventsDatabaseUpdateScript {
+	var highestVersion *semver.Version
+	for i, updateScript := range updateScripts {
+		if len(updateScript.ConfigMap) <= 0 && r.DatabaseScriptWasRun(instance, updateScript.Name) &&
+			(highestVersion == nil || updateScript.Version.GT(*highestVersion)) {
+			highestVersion = &updateScripts[i].Version
+		}
+	}
+
+	pendingScripts := []_deployment.EventsDatabaseUpdateScript{}
+	for _, updateScript := range updateScripts {
+		if r.DatabaseScriptWasRun(instance, updateScript.Name) {
+			continue
+		}
+		if len(updateScript.ConfigMap) <= 0 && highestVersion != nil && updateScript.Version.LTE(*highestVersion) {
+			continue
+		}
+		pendingScripts = append(pendingScripts, updateScript)
+	}
+
+	return pendingScripts
+}
+
 // Predicate to manage all events as a composite predicate
 func appServicePredicateComposite() predicate.Predicate {
 	return predicate.Funcs{
@@ -526,7 +843,10 @@ func appServicePredicate() predicate.Predicate {
 				log.Error(nil, "Update event has no new metadata", "event", e)
 				return false
 			}
-			if e.MetaNew.GetGeneration() == e.MetaOld.GetGeneration() {
+			if e.MetaNew.GetGeneration() == e.MetaOld.GetGeneration() &&
+				e.MetaNew.GetAnnotations()[_deployment.MigrationDriftAcknowledgeAnnotation] == e.MetaOld.GetAnnotations()[_deployment.MigrationDriftAcknowledgeAnnotation] &&
+				e.MetaNew.GetAnnotations()[_deployment.MigrationApprovalAnnotation] == e.MetaOld.GetAnnotations()[_deployment.MigrationApprovalAnnotation] &&
+				e.MetaNew.GetAnnotations()[_deployment.DatabaseUpgradeConfirmAnnotation] == e.MetaOld.GetAnnotations()[_deployment.DatabaseUpgradeConfirmAnnotation] {
 				return false
 			}
 
@@ -611,6 +931,30 @@ func (r *AppServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	err = c.Watch(&source.Kind{Type: &appsv1.StatefulSet{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &gramolav1.AppService{},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &gramolav1.AppService{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Jobs spawned by the scheduled backup CronJob are owned by it, not by the AppService, so they're mapped by label
+	err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(scheduledBackupJobToAppService),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -621,5 +965,20 @@ func (r *AppServiceReconciler) SetupWithManagerOld(mgr ctrl.Manager) error {
 		WithEventFilter(appServicePredicateComposite()).
 		Owns(&corev1.Pod{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Owns(&batchv1beta1.CronJob{}).
 		Complete(r)
 }
+
+// scheduledBackupJobToAppService maps a scheduled backup Job to the AppService it backs up
+func scheduledBackupJobToAppService(o handler.MapObject) []reconcile.Request {
+	name, ok := o.Meta.GetLabels()[_deployment.AppServiceNameLabel]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: name, Namespace: o.Meta.GetNamespace()}},
+	}
+}
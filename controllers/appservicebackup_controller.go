@@ -0,0 +1,144 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	batchv1 "k8s.io/api/batch/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+)
+
+// AppServiceBackupReconciler reconciles a AppServiceBackup object
+type AppServiceBackupReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Best practices...
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicebackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicebackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;patch;update;watch
+
+// Reconcile takes an on-demand backup of the Events Database of the AppService referred by the AppServiceBackup
+func (r *AppServiceBackupReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("appservicebackup", request.NamespacedName)
+	log.Info("Reconciling AppServiceBackup")
+
+	// Fetch the AppServiceBackup instance
+	backup := &gramolav1.AppServiceBackup{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, backup); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Backups are taken only once
+	if backup.Status.Phase == gramolav1.DatabaseOperationPhaseSucceeded || backup.Status.Phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Fetch the AppService whose database is backed up
+	instance := &gramolav1.AppService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: backup.Spec.AppServiceName, Namespace: backup.Namespace}, instance); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return r.updateStatus(backup, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppService %s not found", backup.Spec.AppServiceName))
+		}
+		return reconcile.Result{}, err
+	}
+
+	jobName := _deployment.EventsDatabaseBackupJobNameFor(backup.Name)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: backup.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		if job, err = _deployment.NewEventsDatabaseBackupJob(instance, backup, r.Scheme, jobName); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return reconcile.Result{}, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(backup, "Normal", "Backup Started", "Created %s Job to backup %s", job.Name, _deployment.EventsDatabaseServiceName)
+	}
+
+	backup.Status.Job = job.Name
+	backup.Status.PersistentVolumeClaim = _deployment.EventsDatabaseBackupPersistentVolumeClaimName
+	backup.Status.Path = _deployment.EventsDatabaseBackupPathFor(job.Name)
+	backup.Status.StartTime = job.Status.StartTime
+	backup.Status.CompletionTime = job.Status.CompletionTime
+
+	if job.Status.Succeeded > 0 {
+		r.Recorder.Eventf(backup, "Normal", "Backup Succeeded", "Backup stored in %s", backup.Status.Path)
+		return r.updateStatus(backup, gramolav1.DatabaseOperationPhaseSucceeded, "")
+	}
+	if condition := getJobFailedCondition(job); condition != nil {
+		completionTime := condition.LastTransitionTime
+		backup.Status.CompletionTime = &completionTime
+		r.Recorder.Eventf(backup, "Warning", "Backup Failed", "Job %s failed: %s", job.Name, condition.Message)
+		return r.updateStatus(backup, gramolav1.DatabaseOperationPhaseFailed, condition.Message)
+	}
+	if job.Status.Active > 0 {
+		return r.updateStatus(backup, gramolav1.DatabaseOperationPhaseRunning, "")
+	}
+
+	return r.updateStatus(backup, gramolav1.DatabaseOperationPhasePending, "")
+}
+
+// updateStatus sets the phase of the backup and requeues while it hasn't finished
+func (r *AppServiceBackupReconciler) updateStatus(backup *gramolav1.AppServiceBackup, phase gramolav1.DatabaseOperationPhase, message string) (reconcile.Result, error) {
+	backup.Status.Phase = phase
+	backup.Status.Message = message
+	if err := r.Client.Status().Update(context.TODO(), backup); err != nil {
+		r.Log.Error(err, errorUnableToUpdateStatus, "appservicebackup", backup.Name)
+		return reconcile.Result{}, err
+	}
+
+	if phase == gramolav1.DatabaseOperationPhaseSucceeded || phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+// SetupWithManager is called from main.go
+func (r *AppServiceBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gramolav1.AppServiceBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,240 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+)
+
+// AppServiceDataExportReconciler reconciles a AppServiceDataExport object
+type AppServiceDataExportReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Best practices...
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicedataexports,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicedataexports/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;patch;update;watch
+
+// Reconcile exports the events of the AppService referred by the AppServiceDataExport, exports to a ConfigMap are
+// written by the operator while exports to a PersistentVolumeClaim are written by a Job that mounts it
+func (r *AppServiceDataExportReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("appservicedataexport", request.NamespacedName)
+	log.Info("Reconciling AppServiceDataExport")
+
+	// Fetch the AppServiceDataExport instance
+	export := &gramolav1.AppServiceDataExport{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, export); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Exports are run only once
+	if export.Status.Phase == gramolav1.DatabaseOperationPhaseSucceeded || export.Status.Phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Fetch the AppService whose events are exported
+	instance := &gramolav1.AppService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: export.Spec.AppServiceName, Namespace: export.Namespace}, instance); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppService %s not found", export.Spec.AppServiceName))
+		}
+		return reconcile.Result{}, err
+	}
+
+	export.Status.Format = _deployment.GetEventsDataFormat(export.Spec.Format)
+
+	destination := export.Spec.Destination
+	if destination.ConfigMap != nil && len(destination.ConfigMap.Name) > 0 {
+		return r.exportToConfigMap(instance, export)
+	}
+	if destination.PersistentVolumeClaim != nil && len(destination.PersistentVolumeClaim.Name) > 0 {
+		return r.exportToPersistentVolumeClaim(instance, export)
+	}
+
+	return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, "Either destination.configMap or destination.persistentVolumeClaim has to be set")
+}
+
+// exportToConfigMap writes the events into the key of the destination ConfigMap named after the format, other keys
+// are kept so that one ConfigMap can hold both formats
+func (r *AppServiceDataExportReconciler) exportToConfigMap(instance *gramolav1.AppService, export *gramolav1.AppServiceDataExport) (reconcile.Result, error) {
+	configMapName := export.Spec.Destination.ConfigMap.Name
+	export.Status.Location = &gramolav1.DataLocation{
+		ConfigMap: &corev1.LocalObjectReference{Name: configMapName},
+	}
+
+	credentials, err := readEventsDatabaseCredentials(r.Client, instance)
+	if err != nil {
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhasePending, err.Error())
+	}
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhasePending, fmt.Sprintf("Waiting for %s to be ready: %s", _deployment.EventsDatabaseServiceName, err))
+	}
+	defer db.Close()
+
+	startTime := metav1.Now()
+	export.Status.StartTime = &startTime
+
+	var document string
+	var count int
+	if export.Status.Format == gramolav1.DataFormatCSV {
+		document, count, err = db.ExportEventsCSV(context.TODO())
+	} else {
+		document, count, err = db.ExportEventsJSON(context.TODO())
+	}
+	if err != nil {
+		r.Recorder.Eventf(export, "Warning", "Export Failed", "Failed exporting events: %s", err)
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, err.Error())
+	}
+	if len(document) > _deployment.EventsDataConfigMapMaxSize {
+		message := fmt.Sprintf("%d events take %d bytes, more than a ConfigMap can hold, export them to a PersistentVolumeClaim", count, len(document))
+		r.Recorder.Event(export, "Warning", "Export Failed", message)
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, message)
+	}
+
+	// The ConfigMap outlives the export, it's what is taken to other clusters
+	key := _deployment.GetEventsDataConfigMapKey(export.Status.Format)
+	configMap := _deployment.NewConfigMapFromData(instance, configMapName, export.Namespace, map[string]string{key: document})
+	if err := r.Client.Create(context.TODO(), configMap); err != nil {
+		if !k8s_errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: configMapName, Namespace: export.Namespace}, configMap); err != nil {
+			return reconcile.Result{}, err
+		}
+		patch := client.MergeFrom(configMap.DeepCopy())
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[key] = document
+		if err := r.Client.Patch(context.TODO(), configMap, patch); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	completionTime := metav1.Now()
+	export.Status.Events = int32(count)
+	export.Status.CompletionTime = &completionTime
+	r.Log.Info(fmt.Sprintf("Exported %d events to %s ConfigMap", count, configMapName))
+	r.Recorder.Eventf(export, "Normal", "Export Succeeded", "Exported %d events to ConfigMap %s", count, configMapName)
+	return r.updateStatus(export, gramolav1.DatabaseOperationPhaseSucceeded, "")
+}
+
+// exportToPersistentVolumeClaim runs a Job that writes the events into the destination file
+func (r *AppServiceDataExportReconciler) exportToPersistentVolumeClaim(instance *gramolav1.AppService, export *gramolav1.AppServiceDataExport) (reconcile.Result, error) {
+	claimName := export.Spec.Destination.PersistentVolumeClaim.Name
+	filePath := export.Spec.Destination.Path
+	if len(filePath) <= 0 {
+		filePath = export.Name + _deployment.GetEventsDataFileExtension(export.Status.Format)
+	}
+	filePath, err := _deployment.GetEventsDataFilePath(filePath)
+	if err != nil {
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, err.Error())
+	}
+	export.Status.Location = &gramolav1.DataLocation{
+		PersistentVolumeClaim: &corev1.LocalObjectReference{Name: claimName},
+		Path:                  filePath,
+	}
+
+	jobName := _deployment.EventsDataExportJobNameFor(export.Name)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: export.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		if job, err = _deployment.NewEventsDataExportJob(instance, export, r.Scheme, jobName, export.Status.Format, claimName, export.Status.Location.Path); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(export, "Normal", "Export Started", "Created %s Job to export events to %s", job.Name, claimName)
+	}
+
+	export.Status.Job = job.Name
+	export.Status.StartTime = job.Status.StartTime
+	export.Status.CompletionTime = job.Status.CompletionTime
+
+	if job.Status.Succeeded > 0 {
+		r.Recorder.Eventf(export, "Normal", "Export Succeeded", "Events exported to %s in %s", export.Status.Location.Path, claimName)
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseSucceeded, "")
+	}
+	if condition := getJobFailedCondition(job); condition != nil {
+		completionTime := condition.LastTransitionTime
+		export.Status.CompletionTime = &completionTime
+		r.Recorder.Eventf(export, "Warning", "Export Failed", "Job %s failed: %s", job.Name, condition.Message)
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseFailed, condition.Message)
+	}
+	if job.Status.Active > 0 {
+		return r.updateStatus(export, gramolav1.DatabaseOperationPhaseRunning, "")
+	}
+
+	return r.updateStatus(export, gramolav1.DatabaseOperationPhasePending, "")
+}
+
+// updateStatus sets the phase of the export and requeues while it hasn't finished
+func (r *AppServiceDataExportReconciler) updateStatus(export *gramolav1.AppServiceDataExport, phase gramolav1.DatabaseOperationPhase, message string) (reconcile.Result, error) {
+	export.Status.Phase = phase
+	export.Status.Message = message
+	if err := r.Client.Status().Update(context.TODO(), export); err != nil {
+		r.Log.Error(err, errorUnableToUpdateStatus, "appservicedataexport", export.Name)
+		return reconcile.Result{}, err
+	}
+
+	if phase == gramolav1.DatabaseOperationPhaseSucceeded || phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+// SetupWithManager is called from main.go
+func (r *AppServiceDataExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gramolav1.AppServiceDataExport{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
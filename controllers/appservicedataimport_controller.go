@@ -0,0 +1,251 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+)
+
+// AppServiceDataImportReconciler reconciles a AppServiceDataImport object
+type AppServiceDataImportReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Best practices...
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicedataimports,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicedataimports/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicedataexports,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;patch;update;watch
+
+// Reconcile upserts the events of an export into the events table of the AppService referred by the
+// AppServiceDataImport, imports from a ConfigMap are run by the operator while imports from a PersistentVolumeClaim
+// are run by a Job that mounts it
+func (r *AppServiceDataImportReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("appservicedataimport", request.NamespacedName)
+	log.Info("Reconciling AppServiceDataImport")
+
+	// Fetch the AppServiceDataImport instance
+	dataImport := &gramolav1.AppServiceDataImport{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, dataImport); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Imports are run only once
+	if dataImport.Status.Phase == gramolav1.DatabaseOperationPhaseSucceeded || dataImport.Status.Phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Fetch the AppService whose events are updated
+	instance := &gramolav1.AppService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: dataImport.Spec.AppServiceName, Namespace: dataImport.Namespace}, instance); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppService %s not found", dataImport.Spec.AppServiceName))
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Find out where the events are
+	if dataImport.Status.Location == nil {
+		if len(dataImport.Spec.ExportName) > 0 {
+			export := &gramolav1.AppServiceDataExport{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: dataImport.Spec.ExportName, Namespace: dataImport.Namespace}, export); err != nil {
+				if k8s_errors.IsNotFound(err) {
+					return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppServiceDataExport %s not found", dataImport.Spec.ExportName))
+				}
+				return reconcile.Result{}, err
+			}
+			switch export.Status.Phase {
+			case gramolav1.DatabaseOperationPhaseSucceeded:
+				dataImport.Status.Format = export.Status.Format
+				dataImport.Status.Location = export.Status.Location
+			case gramolav1.DatabaseOperationPhaseFailed:
+				return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppServiceDataExport %s failed", dataImport.Spec.ExportName))
+			default:
+				return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhasePending, fmt.Sprintf("Waiting for AppServiceDataExport %s to succeed", dataImport.Spec.ExportName))
+			}
+		} else if source := dataImport.Spec.Source; source != nil && source.ConfigMap != nil && len(source.ConfigMap.Name) > 0 {
+			dataImport.Status.Format = _deployment.GetEventsDataFormat(dataImport.Spec.Format)
+			dataImport.Status.Location = &gramolav1.DataLocation{ConfigMap: source.ConfigMap}
+		} else if source != nil && source.PersistentVolumeClaim != nil && len(source.PersistentVolumeClaim.Name) > 0 {
+			if len(source.Path) <= 0 {
+				return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, "source.path has to be set to import from a PersistentVolumeClaim")
+			}
+			path, err := _deployment.GetEventsDataFilePath(source.Path)
+			if err != nil {
+				return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, err.Error())
+			}
+			dataImport.Status.Format = _deployment.GetEventsDataFormat(dataImport.Spec.Format)
+			dataImport.Status.Location = &gramolav1.DataLocation{
+				PersistentVolumeClaim: source.PersistentVolumeClaim,
+				Path:                  path,
+			}
+		} else {
+			return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, "Either exportName, source.configMap or source.persistentVolumeClaim has to be set")
+		}
+	}
+
+	if dataImport.Status.Location.ConfigMap != nil {
+		return r.importFromConfigMap(instance, dataImport)
+	}
+	return r.importFromPersistentVolumeClaim(instance, dataImport)
+}
+
+// importFromConfigMap upserts the events in the key of the source ConfigMap named after the format
+func (r *AppServiceDataImportReconciler) importFromConfigMap(instance *gramolav1.AppService, dataImport *gramolav1.AppServiceDataImport) (reconcile.Result, error) {
+	configMapName := dataImport.Status.Location.ConfigMap.Name
+	key := _deployment.GetEventsDataConfigMapKey(dataImport.Status.Format)
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: configMapName, Namespace: dataImport.Namespace}, configMap); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("ConfigMap %s not found", configMapName))
+		}
+		return reconcile.Result{}, err
+	}
+	document, found := configMap.Data[key]
+	if !found {
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("ConfigMap %s has no %s key", configMapName, key))
+	}
+	if dataImport.Status.Format == gramolav1.DataFormatCSV {
+		var err error
+		if document, err = _deployment.ConvertEventsCSVToJSON(document); err != nil {
+			return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("%s in ConfigMap %s is not a valid CSV document: %s", key, configMapName, err))
+		}
+	}
+
+	script, err := _deployment.GetEventsDataImportScript()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	credentials, err := readEventsDatabaseCredentials(r.Client, instance)
+	if err != nil {
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhasePending, err.Error())
+	}
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhasePending, fmt.Sprintf("Waiting for %s to be ready: %s", _deployment.EventsDatabaseServiceName, err))
+	}
+	defer db.Close()
+
+	startTime := metav1.Now()
+	dataImport.Status.StartTime = &startTime
+
+	count, err := db.ImportEvents(context.TODO(), script, document)
+	if err != nil {
+		r.Recorder.Eventf(dataImport, "Warning", "Import Failed", "Failed importing events from ConfigMap %s: %s", configMapName, err)
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, err.Error())
+	}
+
+	completionTime := metav1.Now()
+	dataImport.Status.Events = int32(count)
+	dataImport.Status.CompletionTime = &completionTime
+	r.Log.Info(fmt.Sprintf("Imported %d events from %s ConfigMap", count, configMapName))
+	r.Recorder.Eventf(dataImport, "Normal", "Import Succeeded", "Imported %d events from ConfigMap %s", count, configMapName)
+	return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseSucceeded, "")
+}
+
+// importFromPersistentVolumeClaim runs a Job that upserts the events in the source file
+func (r *AppServiceDataImportReconciler) importFromPersistentVolumeClaim(instance *gramolav1.AppService, dataImport *gramolav1.AppServiceDataImport) (reconcile.Result, error) {
+	location := dataImport.Status.Location
+
+	jobName := _deployment.EventsDataImportJobNameFor(dataImport.Name)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: dataImport.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		if job, err = _deployment.NewEventsDataImportJob(instance, dataImport, r.Scheme, jobName, dataImport.Status.Format, location.PersistentVolumeClaim.Name, location.Path); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(dataImport, "Normal", "Import Started", "Created %s Job to import %s", job.Name, location.Path)
+	}
+
+	dataImport.Status.Job = job.Name
+	dataImport.Status.StartTime = job.Status.StartTime
+	dataImport.Status.CompletionTime = job.Status.CompletionTime
+
+	if job.Status.Succeeded > 0 {
+		r.Recorder.Eventf(dataImport, "Normal", "Import Succeeded", "Imported %s", location.Path)
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseSucceeded, "")
+	}
+	if condition := getJobFailedCondition(job); condition != nil {
+		completionTime := condition.LastTransitionTime
+		dataImport.Status.CompletionTime = &completionTime
+		r.Recorder.Eventf(dataImport, "Warning", "Import Failed", "Job %s failed: %s", job.Name, condition.Message)
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseFailed, condition.Message)
+	}
+	if job.Status.Active > 0 {
+		return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhaseRunning, fmt.Sprintf("Importing %s", location.Path))
+	}
+
+	return r.updateStatus(dataImport, gramolav1.DatabaseOperationPhasePending, "")
+}
+
+// updateStatus sets the phase of the import and requeues while it hasn't finished
+func (r *AppServiceDataImportReconciler) updateStatus(dataImport *gramolav1.AppServiceDataImport, phase gramolav1.DatabaseOperationPhase, message string) (reconcile.Result, error) {
+	dataImport.Status.Phase = phase
+	dataImport.Status.Message = message
+	if err := r.Client.Status().Update(context.TODO(), dataImport); err != nil {
+		r.Log.Error(err, errorUnableToUpdateStatus, "appservicedataimport", dataImport.Name)
+		return reconcile.Result{}, err
+	}
+
+	if phase == gramolav1.DatabaseOperationPhaseSucceeded || phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+// SetupWithManager is called from main.go
+func (r *AppServiceDataImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gramolav1.AppServiceDataImport{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,240 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+)
+
+// AppServiceRestoreReconciler reconciles a AppServiceRestore object
+type AppServiceRestoreReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Best practices...
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicerestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicerestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservicebackups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gramola.atarazana.com,resources=appservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;patch;update;watch
+
+// Reconcile loads a backup into the Events Database of the AppService referred by the AppServiceRestore, the
+// Events Deployment is scaled down while the restore runs and brought back afterwards
+func (r *AppServiceRestoreReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("appservicerestore", request.NamespacedName)
+	log.Info("Reconciling AppServiceRestore")
+
+	// Fetch the AppServiceRestore instance
+	restore := &gramolav1.AppServiceRestore{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, restore); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Restores are run only once
+	if restore.Status.Phase == gramolav1.DatabaseOperationPhaseSucceeded || restore.Status.Phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Fetch the AppService whose database is restored
+	instance := &gramolav1.AppService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: restore.Spec.AppServiceName, Namespace: restore.Namespace}, instance); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppService %s not found", restore.Spec.AppServiceName))
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Find out the backup file to restore
+	if len(restore.Status.Path) <= 0 {
+		if len(restore.Spec.BackupName) > 0 {
+			backup := &gramolav1.AppServiceBackup{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: restore.Spec.BackupName, Namespace: restore.Namespace}, backup); err != nil {
+				if k8s_errors.IsNotFound(err) {
+					return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppServiceBackup %s not found", restore.Spec.BackupName))
+				}
+				return reconcile.Result{}, err
+			}
+			switch backup.Status.Phase {
+			case gramolav1.DatabaseOperationPhaseSucceeded:
+				restore.Status.Path = backup.Status.Path
+			case gramolav1.DatabaseOperationPhaseFailed:
+				return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, fmt.Sprintf("AppServiceBackup %s failed", restore.Spec.BackupName))
+			default:
+				return r.updateStatus(restore, gramolav1.DatabaseOperationPhasePending, fmt.Sprintf("Waiting for AppServiceBackup %s to succeed", restore.Spec.BackupName))
+			}
+		} else if len(restore.Spec.Path) > 0 {
+			path, err := _deployment.GetEventsDatabaseBackupFilePath(restore.Spec.Path)
+			if err != nil {
+				return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, err.Error())
+			}
+			restore.Status.Path = path
+		} else {
+			return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, "Either backupName or path has to be set")
+		}
+	}
+
+	// Scale down Events so that nothing writes to the database while restoring
+	events := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: restore.Namespace}, events); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		events = nil
+	}
+	if events != nil {
+		if events.Annotations[_deployment.RestoreAnnotation] != restore.Name {
+			patch := client.MergeFrom(events.DeepCopy())
+			if restore.Status.EventsReplicas == nil {
+				replicas := _deployment.EventsServiceReplicas
+				if events.Spec.Replicas != nil {
+					replicas = *events.Spec.Replicas
+				}
+				restore.Status.EventsReplicas = &replicas
+			}
+			if events.Annotations == nil {
+				events.Annotations = map[string]string{}
+			}
+			events.Annotations[_deployment.RestoreAnnotation] = restore.Name
+			zero := int32(0)
+			events.Spec.Replicas = &zero
+			if err := r.Client.Patch(context.TODO(), events, patch); err != nil {
+				return reconcile.Result{}, err
+			}
+			r.Recorder.Eventf(restore, "Normal", "Scaled Down", "Scaled down %s Deployment to restore %s", events.Name, restore.Status.Path)
+			return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseRunning, fmt.Sprintf("Scaling down %s", events.Name))
+		}
+		if events.Status.Replicas > 0 {
+			return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseRunning, fmt.Sprintf("Waiting for %s to scale down", events.Name))
+		}
+	}
+
+	// Run the restore
+	jobName := _deployment.EventsDatabaseRestoreJobNameFor(restore.Name)
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: restore.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		if job, err = _deployment.NewEventsDatabaseRestoreJob(instance, restore, r.Scheme, jobName, restore.Status.Path); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return reconcile.Result{}, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(restore, "Normal", "Restore Started", "Created %s Job to restore %s", job.Name, restore.Status.Path)
+	}
+
+	restore.Status.Job = job.Name
+	restore.Status.StartTime = job.Status.StartTime
+	restore.Status.CompletionTime = job.Status.CompletionTime
+
+	if job.Status.Succeeded > 0 {
+		if err := r.scaleEventsBack(restore, events); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Recorder.Eventf(restore, "Normal", "Restore Succeeded", "Restored %s", restore.Status.Path)
+		return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseSucceeded, "")
+	}
+	if condition := getJobFailedCondition(job); condition != nil {
+		if err := r.scaleEventsBack(restore, events); err != nil {
+			return reconcile.Result{}, err
+		}
+		completionTime := condition.LastTransitionTime
+		restore.Status.CompletionTime = &completionTime
+		r.Recorder.Eventf(restore, "Warning", "Restore Failed", "Job %s failed: %s", job.Name, condition.Message)
+		return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseFailed, condition.Message)
+	}
+
+	return r.updateStatus(restore, gramolav1.DatabaseOperationPhaseRunning, fmt.Sprintf("Restoring %s", restore.Status.Path))
+}
+
+// scaleEventsBack brings the Events Deployment back to the replicas it had before the restore
+func (r *AppServiceRestoreReconciler) scaleEventsBack(restore *gramolav1.AppServiceRestore, events *appsv1.Deployment) error {
+	if events == nil {
+		return nil
+	}
+
+	patch := client.MergeFrom(events.DeepCopy())
+	delete(events.Annotations, _deployment.RestoreAnnotation)
+	if restore.Status.EventsReplicas != nil {
+		events.Spec.Replicas = restore.Status.EventsReplicas
+	} else {
+		events.Spec.Replicas = &_deployment.EventsServiceReplicas
+	}
+	if err := r.Client.Patch(context.TODO(), events, patch); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(restore, "Normal", "Scaled Up", "Scaled %s Deployment back to %d replicas", events.Name, *events.Spec.Replicas)
+
+	return nil
+}
+
+// updateStatus sets the phase of the restore and requeues while it hasn't finished
+func (r *AppServiceRestoreReconciler) updateStatus(restore *gramolav1.AppServiceRestore, phase gramolav1.DatabaseOperationPhase, message string) (reconcile.Result, error) {
+	restore.Status.Phase = phase
+	restore.Status.Message = message
+	if phase == gramolav1.DatabaseOperationPhaseFailed && restore.Status.CompletionTime == nil {
+		now := metav1.Now()
+		restore.Status.CompletionTime = &now
+	}
+	if err := r.Client.Status().Update(context.TODO(), restore); err != nil {
+		r.Log.Error(err, errorUnableToUpdateStatus, "appservicerestore", restore.Name)
+		return reconcile.Result{}, err
+	}
+
+	if phase == gramolav1.DatabaseOperationPhaseSucceeded || phase == gramolav1.DatabaseOperationPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+// SetupWithManager is called from main.go
+func (r *AppServiceRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gramolav1.AppServiceRestore{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Reconciling scheduled backups
+func (r *AppServiceReconciler) reconcileBackup(instance *gramolav1.AppService) (reconcile.Result, error) {
+
+	if instance.Spec.Backup == nil {
+		if result, err := r.removeBackup(instance); err != nil {
+			return result, err
+		}
+	} else {
+		if result, err := r.addBackup(instance); err != nil {
+			return result, err
+		}
+	}
+
+	if err := r.updateLastSuccessfulBackupTime(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Success
+	return reconcile.Result{}, nil
+}
+
+func (r *AppServiceReconciler) addBackup(instance *gramolav1.AppService) (reconcile.Result, error) {
+	// PVC for scheduled backups
+	if scheduledBackupPersistentVolumeClaim, err := _deployment.NewEventsDatabaseScheduledBackupPersistentVolumeClaim(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), scheduledBackupPersistentVolumeClaim); err != nil && !errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", scheduledBackupPersistentVolumeClaim.Name))
+			r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", scheduledBackupPersistentVolumeClaim.Name)
+		} else if err := r.expandEventsDatabaseScheduledBackupPersistentVolumeClaim(instance, scheduledBackupPersistentVolumeClaim.Name); err != nil {
+			return reconcile.Result{}, err
+		}
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	if cronJob, err := _deployment.NewEventsDatabaseScheduledBackupCronJob(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), cronJob); err != nil {
+			if errors.IsAlreadyExists(err) {
+				from := &batchv1beta1.CronJob{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseScheduledBackupCronJobPatch(from, instance)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Scheduled backup CronJob created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s CronJob", cronJob.Name))
+		r.Recorder.Eventf(instance, "Normal", "CronJob Created/Updated", "Created/Updated %s CronJob", cronJob.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// expandEventsDatabaseScheduledBackupPersistentVolumeClaim grows the scheduled backups PVC to the size in spec.backup.storage
+func (r *AppServiceReconciler) expandEventsDatabaseScheduledBackupPersistentVolumeClaim(instance *gramolav1.AppService, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, pvc); err != nil {
+		return err
+	}
+
+	return r.expandPersistentVolumeClaim(instance, pvc, _deployment.GetEventsDatabaseScheduledBackupStorageSize(instance))
+}
+
+// removeBackup deletes the scheduled backup CronJob, backup files already taken are kept in the scheduled backups PVC
+func (r *AppServiceReconciler) removeBackup(instance *gramolav1.AppService) (reconcile.Result, error) {
+	cronJob := &batchv1beta1.CronJob{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseScheduledBackupName, Namespace: instance.Namespace}, cronJob); err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if err := r.Client.Delete(context.TODO(), cronJob, client.PropagationPolicy("Background")); err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	log.Info(fmt.Sprintf("Deleted %s CronJob", cronJob.Name))
+	r.Recorder.Eventf(instance, "Normal", "CronJob Deleted", "Deleted %s CronJob", cronJob.Name)
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// updateLastSuccessfulBackupTime records in status the completion time of the latest scheduled backup Job that succeeded
+func (r *AppServiceReconciler) updateLastSuccessfulBackupTime(instance *gramolav1.AppService) error {
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(context.TODO(), jobs, client.InNamespace(instance.Namespace), client.MatchingLabels{
+		"component":                     _deployment.EventsDatabaseScheduledBackupName,
+		_deployment.AppServiceNameLabel: instance.Name,
+	}); err != nil {
+		return err
+	}
+
+	for _, job := range jobs.Items {
+		if job.Status.Succeeded == 0 || job.Status.CompletionTime == nil {
+			continue
+		}
+		if instance.Status.LastSuccessfulBackupTime == nil || instance.Status.LastSuccessfulBackupTime.Before(job.Status.CompletionTime) {
+			instance.Status.LastSuccessfulBackupTime = job.Status.CompletionTime.DeepCopy()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileCredentialsRotation rotates the Events Database password every time spec.database.rotateCredentials is
+// increased, returns true while a rotation is in progress. The new password is kept aside in the credentials Secret
+// and only replaces the current one once the database has accepted it, so a failed rotation leaves everything as it was,
+// is reported in the CredentialsRotationFailed condition and is retried once its Job is deleted. Only the credentials
+// generated by the operator are rotated
+func (r *AppServiceReconciler) reconcileCredentialsRotation(instance *gramolav1.AppService) (bool, error) {
+	if instance.Spec.Database == nil {
+		return false, nil
+	}
+	rotation := instance.Spec.Database.RotateCredentials
+
+	var status *gramolav1.CredentialsRotationStatus
+	if instance.Status.Database != nil {
+		status = instance.Status.Database.CredentialsRotation
+	}
+	requested := rotation > 0 && (status == nil || status.ObservedRotation < rotation)
+
+	// Secrets supplied by the user or pointing to an external server aren't owned by the operator, so they're not changed
+	if !_deployment.AreEventsDatabaseCredentialsGenerated(instance) {
+		if requested {
+			setCondition(instance, gramolav1.AppServiceConditionTypeCredentialsRotationRefused, gramolav1.AppServiceConditionStatusTrue, gramolav1.AppServiceConditionReasonFailed,
+				fmt.Sprintf("Secret %s is not owned by the operator, change the password in the database and update the Secret instead", _deployment.GetEventsDatabaseCredentialsSecretName(instance)))
+		}
+		return false, nil
+	}
+	if isConditionTrue(instance, gramolav1.AppServiceConditionTypeCredentialsRotationRefused) {
+		setCondition(instance, gramolav1.AppServiceConditionTypeCredentialsRotationRefused, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonSucceeded, "Credentials are generated by the operator")
+	}
+	if !requested {
+		return false, nil
+	}
+
+	// Start tracking a new rotation
+	jobName := _deployment.EventsDatabaseCredentialsRotationJobNameFor(rotation)
+	if status == nil || status.Job != jobName {
+		newStatus := &gramolav1.CredentialsRotationStatus{
+			Phase: gramolav1.DatabaseOperationPhasePending,
+			Job:   jobName,
+		}
+		if status != nil {
+			newStatus.ObservedRotation = status.ObservedRotation
+			newStatus.LastRotationTime = status.LastRotationTime
+		}
+		status = newStatus
+		if instance.Status.Database == nil {
+			instance.Status.Database = &gramolav1.DatabaseStatus{}
+		}
+		instance.Status.Database.CredentialsRotation = status
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.GetEventsDatabaseCredentialsSecretName(instance), Namespace: instance.Namespace}, secret); err != nil {
+		return false, err
+	}
+	_, nextPasswordFound := secret.Data[_deployment.EventsDatabaseNextPasswordKey]
+
+	job := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: status.Job, Namespace: instance.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+
+		// Keep the new password aside, it's reused if it was already generated
+		if !nextPasswordFound {
+			patch, err := _deployment.NewEventsDatabaseNextPasswordPatch(secret)
+			if err != nil {
+				return false, err
+			}
+			if err := r.Client.Patch(context.TODO(), secret, patch); err != nil {
+				return false, err
+			}
+		}
+
+		// Create the Job only if there's a database ready to change the password
+		if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+			status.Message = "Waiting for the database to be ready"
+			return true, err
+		}
+		if job, err = _deployment.NewEventsDatabaseCredentialsRotationJob(instance, r.Scheme, rotation); err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(context.TODO(), job); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", job.Name))
+		r.Recorder.Eventf(instance, "Normal", "Credentials Rotation Started", "Created %s Job to rotate %s credentials", job.Name, _deployment.EventsDatabaseServiceName)
+	}
+
+	if job.Status.Succeeded > 0 {
+		// The database accepted the new password, now the Secret and the Events pods can move to it
+		if nextPasswordFound {
+			if err := r.Client.Patch(context.TODO(), secret, _deployment.NewEventsDatabaseSwapPasswordPatch(secret)); err != nil {
+				return false, err
+			}
+		}
+		events := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: instance.Namespace}, events); err != nil {
+			return false, err
+		}
+		if err := r.Client.Patch(context.TODO(), events, _deployment.NewEventsDeploymentRolloutPatch(events, rotation)); err != nil {
+			return false, err
+		}
+
+		completionTime := metav1.Now()
+		if job.Status.CompletionTime != nil {
+			completionTime = *job.Status.CompletionTime
+		}
+		status.Phase = gramolav1.DatabaseOperationPhaseSucceeded
+		status.Message = ""
+		status.ObservedRotation = rotation
+		status.LastRotationTime = &completionTime
+		if isConditionTrue(instance, gramolav1.AppServiceConditionTypeCredentialsRotationFailed) {
+			setCondition(instance, gramolav1.AppServiceConditionTypeCredentialsRotationFailed, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonSucceeded, "Credentials rotated")
+		}
+		log.Info(fmt.Sprintf("Rotated %s credentials", _deployment.EventsDatabaseServiceName))
+		r.Recorder.Eventf(instance, "Normal", "Credentials Rotated", "Rotated %s credentials", _deployment.EventsDatabaseServiceName)
+		return false, nil
+	}
+
+	if condition := getJobFailedCondition(job); condition != nil {
+		// The database kept the current password, so does the Secret
+		if nextPasswordFound {
+			if err := r.Client.Patch(context.TODO(), secret, _deployment.NewEventsDatabaseDiscardPasswordPatch(secret)); err != nil {
+				return false, err
+			}
+		}
+
+		// The rotation is still pending, so it's retried once the failed Job is deleted. Meanwhile the current password
+		// still works and the rest of the reconcile goes on
+		if status.Phase != gramolav1.DatabaseOperationPhaseFailed {
+			log.Info(fmt.Sprintf("Job %s failed to rotate %s credentials: %s", job.Name, _deployment.EventsDatabaseServiceName, condition.Message))
+			r.Recorder.Eventf(instance, "Warning", "Credentials Rotation Failed", "Job %s failed to rotate %s credentials: %s", job.Name, _deployment.EventsDatabaseServiceName, condition.Message)
+		}
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = fmt.Sprintf("Delete %s Job to retry: %s", job.Name, condition.Message)
+		setCondition(instance, gramolav1.AppServiceConditionTypeCredentialsRotationFailed, gramolav1.AppServiceConditionStatusTrue, gramolav1.AppServiceConditionReasonFailed, status.Message)
+		return false, nil
+	}
+
+	// Job still running
+	status.Phase = gramolav1.DatabaseOperationPhaseRunning
+	status.Message = ""
+	return true, nil
+}
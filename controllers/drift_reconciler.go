@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileMigrationDrift compares the checksum recorded for every script run with success against the script as it
+// would be run now. A changed script raises the MigrationDrift condition and an error is returned so that no other
+// script is run until the new checksums are listed in the acknowledge annotation, then they're taken as applied
+func (r *AppServiceReconciler) reconcileMigrationDrift(instance *gramolav1.AppService, checksums map[string]string) error {
+	acknowledged := map[string]bool{}
+	for _, checksum := range strings.Split(instance.Annotations[_deployment.MigrationDriftAcknowledgeAnnotation], ",") {
+		if checksum = strings.TrimSpace(checksum); len(checksum) > 0 {
+			acknowledged[checksum] = true
+		}
+	}
+
+	drifted := []string{}
+	pending := []string{}
+	for i := range instance.Status.EventsDatabaseScriptRuns {
+		scriptRun := &instance.Status.EventsDatabaseScriptRuns[i]
+		checksum, found := checksums[scriptRun.Script]
+		if !found || scriptRun.Status != gramolav1.DatabaseUpdateStatusSucceeded || scriptRun.Checksum == checksum {
+			continue
+		}
+
+		// Runs recorded before checksums were kept take the script as it is now
+		if len(scriptRun.Checksum) <= 0 {
+			scriptRun.Checksum = checksum
+			continue
+		}
+
+		if acknowledged[checksum] {
+			log.Info(fmt.Sprintf("Accepted change of %s from %s to %s", scriptRun.Script, scriptRun.Checksum, checksum))
+			r.Recorder.Eventf(instance, "Normal", "Migration Drift Acknowledged", "Accepted %s as applied with checksum %s", scriptRun.Script, checksum)
+			scriptRun.Checksum = checksum
+			continue
+		}
+
+		drifted = append(drifted, scriptRun.Script)
+		pending = append(pending, checksum)
+	}
+
+	if len(drifted) <= 0 {
+		if isConditionTrue(instance, gramolav1.AppServiceConditionTypeMigrationDrift) {
+			setCondition(instance, gramolav1.AppServiceConditionTypeMigrationDrift, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonSucceeded, "Applied scripts match their recorded checksums")
+		}
+		return nil
+	}
+
+	sort.Strings(drifted)
+	sort.Strings(pending)
+	message := fmt.Sprintf("Applied scripts %s changed, migrations are blocked until annotated with %s=%s",
+		strings.Join(drifted, ", "), _deployment.MigrationDriftAcknowledgeAnnotation, strings.Join(pending, ","))
+	if !isConditionTrue(instance, gramolav1.AppServiceConditionTypeMigrationDrift) {
+		r.Recorder.Event(instance, "Warning", "Migration Drift", message)
+	}
+	setCondition(instance, gramolav1.AppServiceConditionTypeMigrationDrift, gramolav1.AppServiceConditionStatusTrue, gramolav1.AppServiceConditionReasonWaiting, message)
+
+	return errors.New(message)
+}
+
+// isConditionTrue returns true if the condition of the given type is set to True
+func isConditionTrue(instance *gramolav1.AppService, conditionType gramolav1.AppServiceConditionType) bool {
+	for i := range instance.Status.Conditions {
+		if instance.Status.Conditions[i].Type == conditionType {
+			return instance.Status.Conditions[i].Status == gramolav1.AppServiceConditionStatusTrue
+		}
+	}
+	return false
+}
@@ -6,17 +6,21 @@ import (
 	"io/ioutil"
 	"os"
 
+	_errors "github.com/pkg/errors"
 	"github.com/prometheus/common/log"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/atarazana/gramola-operator/database"
 	_deployment "github.com/atarazana/gramola-operator/deployment"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 
 	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
 	// +kubebuilder:scaffold:imports
@@ -44,9 +48,14 @@ func (r *AppServiceReconciler) reconcileEvents(instance *gramolav1.AppService) (
 }
 
 func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconcile.Result, error) {
-	if databaseSecret, err := _deployment.NewEventsDatabaseCredentialsSecret(instance, r.Scheme); err == nil {
-		if err := r.Client.Create(context.TODO(), databaseSecret); err != nil {
-			if errors.IsAlreadyExists(err) {
+	// Generate the Events Database credentials unless the user supplies them, once created the Secret is the source of truth
+	var databaseCredentials map[string]string
+	if _deployment.AreEventsDatabaseCredentialsGenerated(instance) {
+		if databaseSecret, err := _deployment.NewEventsDatabaseCredentialsSecret(instance, r.Scheme); err == nil {
+			if err := r.Client.Create(context.TODO(), databaseSecret); err == nil {
+				// The Secret may not be in the cache yet
+				databaseCredentials = databaseSecret.StringData
+			} else if errors.IsAlreadyExists(err) {
 				from := &corev1.Secret{}
 				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseSecret.Name, Namespace: databaseSecret.Namespace}, from); err == nil {
 					patch := _deployment.NewEventsDatabaseCredentialsSecretPatch(from)
@@ -57,21 +66,33 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 			} else {
 				return reconcile.Result{}, err
 			}
+			// Secret created/updated successfully
+			log.Info(fmt.Sprintf("Created/Updated %s Secret", databaseSecret.Name))
+			r.Recorder.Eventf(instance, "Normal", "Secret Created/Updated", "Created/Updated %s Secret", databaseSecret.Name)
+		} else {
+			return reconcile.Result{}, err
 		}
-		// Secret created/updated successfully
-		log.Info(fmt.Sprintf("Created/Updated %s Secret", databaseSecret.Name))
-		r.Recorder.Eventf(instance, "Normal", "Secret Created/Updated", "Created/Updated %s Secret", databaseSecret.Name)
-	} else {
-		return reconcile.Result{}, err
+	}
+
+	if databaseCredentials == nil {
+		credentials, err := r.getEventsDatabaseCredentials(instance)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		databaseCredentials = credentials
 	}
 
 	// Create Events Database Script ConfigMap
-	if databaseScriptsConfigMap, err := _deployment.NewEventsDatabaseScriptsConfigMap(instance, r.Scheme); err == nil {
+	updateScripts, err := r.getEventsDatabaseUpdateScripts(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if databaseScriptsConfigMap, err := _deployment.NewEventsDatabaseScriptsConfigMap(instance, r.Scheme, updateScripts, _deployment.NewEventsDatabaseScriptContext(instance, databaseCredentials)); err == nil {
 		if err := r.Client.Create(context.TODO(), databaseScriptsConfigMap); err != nil {
 			if errors.IsAlreadyExists(err) {
 				from := &corev1.ConfigMap{}
 				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseScriptsConfigMap.Name, Namespace: databaseScriptsConfigMap.Namespace}, from); err == nil {
-					patch := _deployment.NewEventsDatabaseScriptsConfigMapPatch(from)
+					patch := _deployment.NewEventsDatabaseScriptsConfigMapPatch(from, databaseScriptsConfigMap.Data)
 					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
 						return reconcile.Result{}, err
 					}
@@ -87,25 +108,37 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 		return reconcile.Result{}, err
 	}
 
-	// PVC for Events Database
-	databasePersistentVolumeClaim := _deployment.NewPersistentVolumeClaim(instance, _deployment.EventsDatabaseServiceName, instance.Namespace, "512Mi")
-	if err := controllerutil.SetControllerReference(instance, databasePersistentVolumeClaim, r.Scheme); err != nil {
-		return reconcile.Result{}, err
+	// Events Database deployed by the operator unless an external one is used
+	if !_deployment.IsEventsDatabaseExternal(instance) {
+		if result, err := r.addEventsDatabase(instance); err != nil {
+			return result, err
+		}
 	}
-	if err := r.Client.Create(context.TODO(), databasePersistentVolumeClaim); err != nil && !errors.IsAlreadyExists(err) {
+
+	// PVC for Events Database backups
+	if backupPersistentVolumeClaim, err := _deployment.NewEventsDatabaseBackupPersistentVolumeClaim(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), backupPersistentVolumeClaim); err != nil && !errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", backupPersistentVolumeClaim.Name))
+			r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", backupPersistentVolumeClaim.Name)
+		} else if err := r.expandEventsDatabaseBackupPersistentVolumeClaim(instance, backupPersistentVolumeClaim.Name); err != nil {
+			return reconcile.Result{}, err
+		}
+	} else {
 		return reconcile.Result{}, err
-	} else if err == nil {
-		log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", databasePersistentVolumeClaim.Name))
-		r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", databasePersistentVolumeClaim.Name)
 	}
 
-	// Adds environment variables from the secret values passed and also mounts a volume with the configmap also passed in
-	if databaseDeployment, err := _deployment.NewEventsDatabaseDeployment(instance, r.Scheme); err == nil {
-		if err := r.Client.Create(context.TODO(), databaseDeployment); err != nil {
+	pooled, err := r.isEventsDatabasePoolerInUse(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if eventsDeployment, err := _deployment.NewEventsDeployment(instance, r.Scheme, pooled); err == nil {
+		if err := r.Client.Create(context.TODO(), eventsDeployment); err != nil {
 			if errors.IsAlreadyExists(err) {
 				from := &appsv1.Deployment{}
-				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseDeployment.Name, Namespace: databaseDeployment.Namespace}, from); err == nil {
-					patch := _deployment.NewEventsDatabaseDeploymentPatch(from)
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: eventsDeployment.Name, Namespace: eventsDeployment.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDeploymentPatch(from, instance, pooled)
 					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
 						return reconcile.Result{}, err
 					}
@@ -115,18 +148,18 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 			}
 		}
 		// Events Database Deployment created/updated successfully
-		log.Info(fmt.Sprintf("Created/Updated %s Deployment", databaseDeployment.Name))
-		r.Recorder.Eventf(instance, "Normal", "Deployment Created/Updated", "Created/Updated %s Deployment", databaseDeployment.Name)
+		log.Info(fmt.Sprintf("Created/Updated %s Deployment", eventsDeployment.Name))
+		r.Recorder.Eventf(instance, "Normal", "Deployment Created/Updated", "Created/Updated %s Deployment", eventsDeployment.Name)
 	} else {
 		return reconcile.Result{}, err
 	}
 
-	if databaseService, err := _deployment.NewEventsDatabaseService(instance, r.Scheme); err == nil {
-		if err := r.Client.Create(context.TODO(), databaseService); err != nil {
+	if eventsService, err := _deployment.NewEventsService(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), eventsService); err != nil {
 			if errors.IsAlreadyExists(err) {
 				from := &corev1.Service{}
-				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseService.Name, Namespace: databaseService.Namespace}, from); err == nil {
-					patch := _deployment.NewEventsDatabaseServicePatch(from)
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: eventsService.Name, Namespace: eventsService.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsServicePatch(from)
 					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
 						return reconcile.Result{}, err
 					}
@@ -135,19 +168,41 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 				return reconcile.Result{}, err
 			}
 		}
-		// Events Database Service created/updated successfully
-		log.Info(fmt.Sprintf("Created/Updated %s Service", databaseService.Name))
-		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", databaseService.Name)
+		// Events Database Deployment created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Service", eventsService.Name))
+		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", eventsService.Name)
 	} else {
 		return reconcile.Result{}, err
 	}
 
-	if eventsDeployment, err := _deployment.NewEventsDeployment(instance, r.Scheme); err == nil {
-		if err := r.Client.Create(context.TODO(), eventsDeployment); err != nil {
+	//Success
+	return reconcile.Result{}, nil
+}
+
+func (r *AppServiceReconciler) addEventsDatabase(instance *gramolav1.AppService) (reconcile.Result, error) {
+	// The primary reads the replication credentials from the start
+	if _deployment.IsEventsDatabaseReplicated(instance) {
+		if err := r.addEventsDatabaseReplicationSecret(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if _deployment.IsEventsDatabaseStatefulSet(instance) {
+		if result, err := r.addEventsDatabaseStatefulSet(instance); err != nil {
+			return result, err
+		}
+	} else {
+		if result, err := r.addEventsDatabaseDeployment(instance); err != nil {
+			return result, err
+		}
+	}
+
+	if databaseService, err := _deployment.NewEventsDatabaseService(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), databaseService); err != nil {
 			if errors.IsAlreadyExists(err) {
-				from := &appsv1.Deployment{}
-				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: eventsDeployment.Name, Namespace: eventsDeployment.Namespace}, from); err == nil {
-					patch := _deployment.NewEventsDeploymentPatch(from)
+				from := &corev1.Service{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseService.Name, Namespace: databaseService.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseServicePatch(from)
 					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
 						return reconcile.Result{}, err
 					}
@@ -156,19 +211,47 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 				return reconcile.Result{}, err
 			}
 		}
-		// Events Database Deployment created/updated successfully
-		log.Info(fmt.Sprintf("Created/Updated %s Deployment", eventsDeployment.Name))
-		r.Recorder.Eventf(instance, "Normal", "Deployment Created/Updated", "Created/Updated %s Deployment", eventsDeployment.Name)
+		// Events Database Service created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Service", databaseService.Name))
+		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", databaseService.Name)
 	} else {
 		return reconcile.Result{}, err
 	}
 
-	if eventsService, err := _deployment.NewEventsService(instance, r.Scheme); err == nil {
-		if err := r.Client.Create(context.TODO(), eventsService); err != nil {
+	//Success
+	return reconcile.Result{}, nil
+}
+
+func (r *AppServiceReconciler) addEventsDatabaseDeployment(instance *gramolav1.AppService) (reconcile.Result, error) {
+	// Once the data lives in the StatefulSet volume there's no way back
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}, statefulSet); err == nil {
+		return reconcile.Result{}, _errors.Errorf("%s runs as a StatefulSet, moving it back to a Deployment isn't supported", _deployment.EventsDatabaseServiceName)
+	} else if !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	// PVC for Events Database
+	if databasePersistentVolumeClaim, err := _deployment.NewEventsDatabasePersistentVolumeClaim(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), databasePersistentVolumeClaim); err != nil && !errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", databasePersistentVolumeClaim.Name))
+			r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", databasePersistentVolumeClaim.Name)
+		} else if err := r.expandEventsDatabasePersistentVolumeClaim(instance, databasePersistentVolumeClaim.Name); err != nil {
+			return reconcile.Result{}, err
+		}
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	// Adds environment variables from the secret values passed and also mounts a volume with the configmap also passed in
+	if databaseDeployment, err := _deployment.NewEventsDatabaseDeployment(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), databaseDeployment); err != nil {
 			if errors.IsAlreadyExists(err) {
-				from := &corev1.Service{}
-				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: eventsService.Name, Namespace: eventsService.Namespace}, from); err == nil {
-					patch := _deployment.NewEventsServicePatch(from)
+				from := &appsv1.Deployment{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: databaseDeployment.Name, Namespace: databaseDeployment.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseDeploymentPatch(from, instance)
 					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
 						return reconcile.Result{}, err
 					}
@@ -178,16 +261,170 @@ func (r *AppServiceReconciler) addEvents(instance *gramolav1.AppService) (reconc
 			}
 		}
 		// Events Database Deployment created/updated successfully
-		log.Info(fmt.Sprintf("Created/Updated %s Service", eventsService.Name))
-		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", eventsService.Name)
+		log.Info(fmt.Sprintf("Created/Updated %s Deployment", databaseDeployment.Name))
+		r.Recorder.Eventf(instance, "Normal", "Deployment Created/Updated", "Created/Updated %s Deployment", databaseDeployment.Name)
 	} else {
 		return reconcile.Result{}, err
 	}
 
+	setEventsDatabaseWorkload(instance, gramolav1.DatabaseWorkloadDeployment)
+
 	//Success
 	return reconcile.Result{}, nil
 }
 
+// setEventsDatabaseWorkload records in status the kind of workload the Events Database runs as
+func setEventsDatabaseWorkload(instance *gramolav1.AppService, workload gramolav1.DatabaseWorkload) {
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	instance.Status.Database.Workload = workload
+}
+
+// expandEventsDatabasePersistentVolumeClaim grows the given Events Database PVC online when a bigger size is requested and
+// its StorageClass allows it, it also records the capacity of the bound volume in status
+func (r *AppServiceReconciler) expandEventsDatabasePersistentVolumeClaim(instance *gramolav1.AppService, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, pvc); err != nil {
+		return err
+	}
+
+	if pvc.Status.Phase == corev1.ClaimBound {
+		if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			if instance.Status.Database == nil {
+				instance.Status.Database = &gramolav1.DatabaseStatus{}
+			}
+			instance.Status.Database.Capacity = &capacity
+		}
+	}
+
+	return r.expandPersistentVolumeClaim(instance, pvc, _deployment.GetEventsDatabaseStorageSize(instance))
+}
+
+// expandPersistentVolumeClaim grows the given PVC online to size when it's bigger than the requested one and its
+// StorageClass allows it
+func (r *AppServiceReconciler) expandPersistentVolumeClaim(instance *gramolav1.AppService, pvc *corev1.PersistentVolumeClaim, size resource.Quantity) error {
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	switch size.Cmp(requested) {
+	case 0:
+		return nil
+	case -1:
+		r.Recorder.Eventf(instance, "Warning", "PVC Not Resized", "%s Persistent Volume Claim can't shrink from %s to %s", pvc.Name, requested.String(), size.String())
+		return nil
+	}
+
+	if pvc.Spec.StorageClassName == nil || len(*pvc.Spec.StorageClassName) <= 0 {
+		r.Recorder.Eventf(instance, "Warning", "PVC Not Resized", "%s Persistent Volume Claim has no StorageClass to expand it", pvc.Name)
+		return nil
+	}
+	storageClass := &storagev1.StorageClass{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+		return err
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		r.Recorder.Eventf(instance, "Warning", "PVC Not Resized", "%s StorageClass doesn't allow to expand %s Persistent Volume Claim", storageClass.Name, pvc.Name)
+		return nil
+	}
+
+	if err := r.Client.Patch(context.TODO(), pvc, _deployment.NewEventsDatabasePersistentVolumeClaimPatch(pvc, size)); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Expanding %s Persistent Volume Claim from %s to %s", pvc.Name, requested.String(), size.String()))
+	r.Recorder.Eventf(instance, "Normal", "PVC Resized", "Expanding %s Persistent Volume Claim from %s to %s", pvc.Name, requested.String(), size.String())
+
+	return nil
+}
+
+// expandEventsDatabaseBackupPersistentVolumeClaim grows the backups PVC along with the Events Database volume
+func (r *AppServiceReconciler) expandEventsDatabaseBackupPersistentVolumeClaim(instance *gramolav1.AppService, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, pvc); err != nil {
+		return err
+	}
+
+	return r.expandPersistentVolumeClaim(instance, pvc, _deployment.GetEventsDatabaseBackupStorageSize(instance))
+}
+
+// getEventsDatabaseCredentials returns the Events Database credentials from their Secret, which is the source of truth
+func (r *AppServiceReconciler) getEventsDatabaseCredentials(instance *gramolav1.AppService) (map[string]string, error) {
+	return readEventsDatabaseCredentials(r.Client, instance)
+}
+
+// readEventsDatabaseCredentials reads the Events Database credentials Secret of the given AppService, it's shared
+// with the controllers that connect to the database on behalf of an AppService
+func readEventsDatabaseCredentials(c client.Client, instance *gramolav1.AppService) (map[string]string, error) {
+	secretName := _deployment.GetEventsDatabaseCredentialsSecretName(instance)
+
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: instance.Namespace}, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, _errors.Errorf("Events Database credentials Secret %s not found", secretName)
+		}
+		return nil, err
+	}
+
+	credentials := map[string]string{}
+	keys := []string{_deployment.EventsDatabaseUserKey, _deployment.EventsDatabasePasswordKey, _deployment.EventsDatabaseNameKey}
+	// The name of an external database is set in the spec
+	if _deployment.IsEventsDatabaseExternal(instance) {
+		keys = keys[:2]
+		credentials[_deployment.EventsDatabaseNameKey] = instance.Spec.Database.External.DatabaseName
+	}
+	for _, key := range keys {
+		value, ok := secret.Data[key]
+		if !ok || len(value) == 0 {
+			return nil, _errors.Errorf("Events Database credentials Secret %s has no %s key", secretName, key)
+		}
+		credentials[key] = string(value)
+	}
+
+	// The certificate authority of an external database is needed to connect to it too
+	if caSecretName := _deployment.GetEventsDatabaseCASecretName(instance); len(caSecretName) > 0 {
+		caSecret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: caSecretName, Namespace: instance.Namespace}, caSecret); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, _errors.Errorf("Events Database certificate authority Secret %s not found", caSecretName)
+			}
+			return nil, err
+		}
+		value, ok := caSecret.Data[_deployment.EventsDatabaseCACertKey]
+		if !ok || len(value) == 0 {
+			return nil, _errors.Errorf("Events Database certificate authority Secret %s has no %s key", caSecretName, _deployment.EventsDatabaseCACertKey)
+		}
+		credentials[_deployment.EventsDatabaseCACertKey] = string(value)
+	}
+
+	return credentials, nil
+}
+
+// connectEventsDatabase connects to the Events Database member in the given host, or through its Service if empty.
+// Members are always deployed by the operator, so connections to them don't use TLS
+func connectEventsDatabase(instance *gramolav1.AppService, host string, credentials map[string]string) (*database.Client, error) {
+	port := _deployment.EventsDatabaseServicePort
+	sslMode := gramolav1.DatabaseSSLModeDisable
+	sslRootCert := ""
+	if len(host) <= 0 {
+		sslMode = _deployment.GetEventsDatabaseSSLMode(instance)
+		sslRootCert = credentials[_deployment.EventsDatabaseCACertKey]
+		host = _deployment.GetEventsDatabaseHost(instance)
+		port = _deployment.GetEventsDatabasePort(instance)
+		// The operator doesn't run in the namespace of the AppService
+		if !_deployment.IsEventsDatabaseExternal(instance) {
+			host = host + "." + instance.Namespace + ".svc"
+		}
+	}
+
+	return database.Connect(context.TODO(), database.Config{
+		Host:        host,
+		Port:        port,
+		User:        credentials[_deployment.EventsDatabaseUserKey],
+		Password:    credentials[_deployment.EventsDatabasePasswordKey],
+		Database:    credentials[_deployment.EventsDatabaseNameKey],
+		SSLMode:     string(sslMode),
+		SSLRootCert: sslRootCert,
+	})
+}
+
 func readFile(fileName string) (string, error) {
 	filePath := _deployment.DbScriptsBasePath + "/" + fileName
 	log.Info(fmt.Sprintf("Reading file %s", fileName))
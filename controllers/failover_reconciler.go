@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileFailover promotes the replica named in spec.database.promote, returns true while the failover is in progress.
+// Every step is derived from the state of the primary workload, the promote ConfigMap and the promoted replica, so
+// the failover resumes where it was if the operator restarts:
+//  1. the primary is fenced by scaling it down, so that it can't take writes anymore
+//  2. the trigger file of the replica is added to the promote ConfigMap
+//  3. once the replica left recovery the events-database Service is pointed to it
+func (r *AppServiceReconciler) reconcileFailover(instance *gramolav1.AppService) (bool, error) {
+	if instance.Spec.Database == nil || !_deployment.IsEventsDatabaseReplicated(instance) {
+		return false, nil
+	}
+	target := instance.Spec.Database.Promote
+	primary := _deployment.GetEventsDatabasePromotedPrimary(instance)
+
+	// Nothing requested or already done, just make sure the Service still points to the promoted replica
+	if len(target) <= 0 || target == primary {
+		if len(primary) > 0 {
+			return false, r.pointEventsDatabaseServiceTo(instance, primary)
+		}
+		return false, nil
+	}
+
+	if len(primary) > 0 {
+		message := fmt.Sprintf("%s was already promoted, only the original primary can be failed over", primary)
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonFailed, message)
+		return false, errors.New(message)
+	}
+	if ordinal, ok := _deployment.EventsDatabaseReplicaOrdinal(target); !ok || int32(ordinal) >= _deployment.GetEventsDatabaseReplicas(instance) {
+		message := fmt.Sprintf("%s is not a replica of %s", target, _deployment.EventsDatabaseServiceName)
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonFailed, message)
+		return false, errors.New(message)
+	}
+
+	pod := &corev1.Pod{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: target, Namespace: instance.Namespace}, pod); err != nil && !k8s_errors.IsNotFound(err) {
+		return false, err
+	} else if err != nil {
+		pod = nil
+	}
+
+	// 1. Fence the primary, but only if there's a replica ready to take over
+	fenced, err := r.fenceEventsDatabasePrimary(instance, pod)
+	if err != nil || !fenced {
+		return err == nil, err
+	}
+	if pod == nil {
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonWaiting, fmt.Sprintf("Waiting for %s pod", target))
+		return true, nil
+	}
+
+	// 2. Promote the replica
+	configMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabasePromoteConfigMapName, Namespace: instance.Namespace}, configMap); err != nil {
+		return false, err
+	}
+	if _, ok := configMap.Data[target]; !ok {
+		if err := r.Client.Patch(context.TODO(), configMap, _deployment.NewEventsDatabasePromotePatch(configMap, target)); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Promoting %s", target))
+		r.Recorder.Eventf(instance, "Normal", "Failover Promoting", "Promoting %s to primary", target)
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonProgressing, fmt.Sprintf("Promoting %s", target))
+		return true, nil
+	}
+
+	// The trigger file shows up once the kubelet syncs the ConfigMap volume
+	if promoted, err := r.isEventsDatabasePromoted(instance, pod); err != nil || !promoted {
+		message := fmt.Sprintf("Waiting for %s to leave recovery", target)
+		if err != nil {
+			message = fmt.Sprintf("%s: %s", message, err)
+		}
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonProgressing, message)
+		return true, nil
+	}
+
+	// 3. Send the traffic to the new primary
+	if err := r.pointEventsDatabaseServiceTo(instance, target); err != nil {
+		return false, err
+	}
+
+	instance.Status.Database.Primary = target
+	setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusTrue, gramolav1.AppServiceConditionReasonSucceeded, fmt.Sprintf("%s is the primary", target))
+	log.Info(fmt.Sprintf("Failed over %s to %s", _deployment.EventsDatabaseServiceName, target))
+	r.Recorder.Eventf(instance, "Normal", "Failover Succeeded", "Failed over %s to %s", _deployment.EventsDatabaseServiceName, target)
+
+	return false, nil
+}
+
+// fenceEventsDatabasePrimary scales the primary workload down, returns true once none of its pods is left
+func (r *AppServiceReconciler) fenceEventsDatabasePrimary(instance *gramolav1.AppService, target *corev1.Pod) (bool, error) {
+	key := types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}
+
+	// The primary is still up if its workload wants any replicas
+	var current runtime.Object
+	var patch client.Patch
+	if _deployment.IsEventsDatabaseStatefulSet(instance) {
+		statefulSet := &appsv1.StatefulSet{}
+		if err := r.Client.Get(context.TODO(), key, statefulSet); err != nil && !k8s_errors.IsNotFound(err) {
+			return false, err
+		} else if err == nil && (statefulSet.Spec.Replicas == nil || *statefulSet.Spec.Replicas > 0) {
+			current, patch = statefulSet, _deployment.NewEventsDatabaseStatefulSetScalePatch(statefulSet, 0)
+		}
+	} else {
+		deployment := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), key, deployment); err != nil && !k8s_errors.IsNotFound(err) {
+			return false, err
+		} else if err == nil && (deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0) {
+			current, patch = deployment, _deployment.NewEventsDatabaseDeploymentScalePatch(deployment, 0)
+		}
+	}
+
+	if current != nil {
+		if target == nil || !isPodReady(target) {
+			message := fmt.Sprintf("%s is not ready to be promoted", instance.Spec.Database.Promote)
+			setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonFailed, message)
+			return false, errors.New(message)
+		}
+
+		if err := r.Client.Patch(context.TODO(), current, patch); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Fenced %s primary", _deployment.EventsDatabaseServiceName))
+		r.Recorder.Eventf(instance, "Normal", "Failover Started", "Scaled down %s primary to promote %s", _deployment.EventsDatabaseServiceName, target.Name)
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonProgressing, "Fencing the primary")
+		return false, nil
+	}
+
+	// Wait until the primary is actually stopped
+	podList := &corev1.PodList{}
+	listOps := &client.ListOptions{Namespace: instance.Namespace, LabelSelector: labels.SelectorFromSet(map[string]string{"component": _deployment.EventsDatabaseServiceName})}
+	if err := r.Client.List(context.TODO(), podList, listOps); err != nil {
+		return false, err
+	}
+	if len(podList.Items) > 0 {
+		setCondition(instance, gramolav1.AppServiceConditionTypeFailover, gramolav1.AppServiceConditionStatusFalse, gramolav1.AppServiceConditionReasonProgressing, "Waiting for the primary to stop")
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// isEventsDatabasePromoted returns true if the given pod runs PostgreSQL out of recovery
+func (r *AppServiceReconciler) isEventsDatabasePromoted(instance *gramolav1.AppService, pod *corev1.Pod) (bool, error) {
+	if !isPodReady(pod) {
+		return false, nil
+	}
+	credentials, err := r.getEventsDatabaseCredentials(instance)
+	if err != nil {
+		return false, err
+	}
+
+	member, err := connectEventsDatabase(instance, pod.Status.PodIP, credentials)
+	if err != nil {
+		return false, err
+	}
+	defer member.Close()
+
+	inRecovery, err := member.IsInRecovery(context.TODO())
+	if err != nil {
+		return false, err
+	}
+
+	return !inRecovery, nil
+}
+
+// pointEventsDatabaseServiceTo makes the events-database Service select only the given pod
+func (r *AppServiceReconciler) pointEventsDatabaseServiceTo(instance *gramolav1.AppService, podName string) error {
+	service := &corev1.Service{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}, service); err != nil {
+		return err
+	}
+	if service.Spec.Selector[_deployment.StatefulSetPodNameLabel] == podName && len(service.Spec.Selector) == 1 {
+		return nil
+	}
+
+	if err := r.Client.Patch(context.TODO(), service, _deployment.NewEventsDatabaseServicePrimaryPatch(service, podName)); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Pointed %s Service to %s", service.Name, podName))
+	r.Recorder.Eventf(instance, "Normal", "Service Updated", "Pointed %s Service to %s", service.Name, podName)
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/atarazana/gramola-operator/database"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// getEventsDatabaseUpdateScripts returns the migration plan, the built-in update scripts followed by the custom ones
+// in the order their ConfigMaps are listed in spec.database.customScripts
+func (r *AppServiceReconciler) getEventsDatabaseUpdateScripts(instance *gramolav1.AppService) ([]_deployment.EventsDatabaseUpdateScript, error) {
+	updateScripts, err := _deployment.GetEventsDatabaseUpdateScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range _deployment.GetEventsDatabaseCustomScriptsConfigMaps(instance) {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, configMap); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return nil, errors.Errorf("Custom scripts ConfigMap %s not found", name)
+			}
+			return nil, err
+		}
+		customScripts, err := _deployment.GetEventsDatabaseCustomScripts(configMap)
+		if err != nil {
+			return nil, err
+		}
+		updateScripts = append(updateScripts, customScripts...)
+	}
+
+	return updateScripts, nil
+}
+
+// setPendingDatabaseScripts records in the status the update scripts not run yet
+func setPendingDatabaseScripts(instance *gramolav1.AppService, pendingScripts []_deployment.EventsDatabaseUpdateScript) {
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	instance.Status.Database.PendingScripts = nil
+	for _, pendingScript := range pendingScripts {
+		instance.Status.Database.PendingScripts = append(instance.Status.Database.PendingScripts, pendingScript.Name)
+	}
+}
+
+// approvedDatabaseScripts returns, keeping the order, the pending scripts up to the version approved in the AppService.
+// Custom scripts come after every built-in one, so approving a custom script approves the built-in ones too
+func approvedDatabaseScripts(instance *gramolav1.AppService, pendingScripts []_deployment.EventsDatabaseUpdateScript) ([]_deployment.EventsDatabaseUpdateScript, error) {
+	approval, found := instance.Annotations[_deployment.MigrationApprovalAnnotation]
+	if !found || len(approval) <= 0 {
+		return nil, nil
+	}
+	for i := len(pendingScripts) - 1; i >= 0; i-- {
+		if pendingScripts[i].TrackedVersion() == approval {
+			return pendingScripts[:i+1], nil
+		}
+	}
+	approvedVersion, err := semver.Parse(approval)
+	if err != nil {
+		return nil, errors.Errorf("Annotation %s has not a valid version: %s", _deployment.MigrationApprovalAnnotation, err)
+	}
+
+	approvedScripts := []_deployment.EventsDatabaseUpdateScript{}
+	for _, pendingScript := range pendingScripts {
+		if len(pendingScript.ConfigMap) <= 0 && pendingScript.Version.LTE(approvedVersion) {
+			approvedScripts = append(approvedScripts, pendingScript)
+		}
+	}
+
+	return approvedScripts, nil
+}
+
+// dryRunEventsDatabaseScripts runs the pending scripts inside a transaction that is rolled back and records the
+// outcome in the status, returns true once the dry run of the current pending scripts has finished
+func (r *AppServiceReconciler) dryRunEventsDatabaseScripts(instance *gramolav1.AppService, pendingScripts []_deployment.EventsDatabaseUpdateScript, credentials map[string]string, checksums map[string]string) (bool, error) {
+	scriptNames := []string{}
+	scriptChecksums := []string{}
+	for _, pendingScript := range pendingScripts {
+		scriptNames = append(scriptNames, pendingScript.Name)
+		scriptChecksums = append(scriptChecksums, checksums[pendingScript.Name])
+	}
+	scriptsChecksum := _deployment.EventsDatabaseDryRunChecksumFor(scriptChecksums)
+
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	status := instance.Status.Database.DryRun
+	if status != nil && status.ScriptsChecksum == scriptsChecksum && (status.Phase == gramolav1.DatabaseOperationPhaseSucceeded || status.Phase == gramolav1.DatabaseOperationPhaseFailed) {
+		return true, nil
+	}
+
+	// The pending scripts changed, the previous dry run is not relevant anymore
+	if status == nil || status.ScriptsChecksum != scriptsChecksum {
+		status = &gramolav1.MigrationDryRunStatus{
+			Phase:           gramolav1.DatabaseOperationPhasePending,
+			ScriptsChecksum: scriptsChecksum,
+			Scripts:         scriptNames,
+		}
+		instance.Status.Database.DryRun = status
+	}
+
+	// Run the scripts only if there's a database ready to run them
+	if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+		return false, err
+	}
+	scriptContext := _deployment.NewEventsDatabaseScriptContext(instance, credentials)
+	scripts := []string{}
+	for _, pendingScript := range pendingScripts {
+		script, err := _deployment.RenderEventsDatabaseUpdateScript(pendingScript, scriptContext)
+		if err != nil {
+			return false, err
+		}
+		scripts = append(scripts, script)
+	}
+
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	log.Info(fmt.Sprintf("Dry running %s on %s", strings.Join(scriptNames, ", "), _deployment.EventsDatabaseServiceName))
+	ctx, cancel := context.WithTimeout(context.TODO(), eventsDatabaseScriptTimeout)
+	defer cancel()
+	result, err := db.DryRunScripts(ctx, scripts)
+	if result == nil {
+		return false, err
+	}
+
+	completionTime := metav1.Now()
+	status.Notices = result.Notices
+	status.AffectedTables = result.AffectedTables
+	status.CompletionTime = &completionTime
+	if err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Error = fmt.Sprintf("%s: %s", scriptNames[result.FailedScript], err)
+		r.Recorder.Eventf(instance, "Warning", "Dry Run Failed", "Dry run of %s failed: %s", strings.Join(scriptNames, ", "), status.Error)
+	} else {
+		status.Phase = gramolav1.DatabaseOperationPhaseSucceeded
+		status.Error = ""
+		r.Recorder.Eventf(instance, "Normal", "Dry Run Succeeded", "Dry run of %s succeeded", strings.Join(scriptNames, ", "))
+	}
+
+	return true, nil
+}
+
+// newDatabaseScriptError returns the error a script failed with as recorded in the status
+func newDatabaseScriptError(err error) *gramolav1.DatabaseScriptError {
+	scriptError, ok := err.(*database.ScriptError)
+	if !ok {
+		return &gramolav1.DatabaseScriptError{Message: err.Error()}
+	}
+
+	return &gramolav1.DatabaseScriptError{
+		SQLState: scriptError.SQLState,
+		Message:  scriptError.Message,
+		Line:     scriptError.Line,
+		Detail:   scriptError.Detail,
+		Hint:     scriptError.Hint,
+	}
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	semver "github.com/blang/semver"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+)
+
+func TestApprovedDatabaseScripts(t *testing.T) {
+	pendingScripts := []_deployment.EventsDatabaseUpdateScript{
+		{Name: "events-database-update-0.0.3.sql", Version: semver.MustParse("0.0.3")},
+		{Name: "events-database-update-0.0.4.sql", Version: semver.MustParse("0.0.4")},
+		{Name: "reporting.views-0.0.1.sql", Version: semver.MustParse("0.0.1"), ConfigMap: "reporting"},
+	}
+
+	tests := []struct {
+		name     string
+		approval *string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "no approval",
+		},
+		{
+			name:     "empty approval",
+			approval: stringPtr(""),
+		},
+		{
+			name:     "first built-in script",
+			approval: stringPtr("0.0.3"),
+			want:     []string{"events-database-update-0.0.3.sql"},
+		},
+		{
+			name:     "last built-in script",
+			approval: stringPtr("0.0.4"),
+			want:     []string{"events-database-update-0.0.3.sql", "events-database-update-0.0.4.sql"},
+		},
+		{
+			name:     "version above the built-in scripts",
+			approval: stringPtr("1.0.0"),
+			want:     []string{"events-database-update-0.0.3.sql", "events-database-update-0.0.4.sql"},
+		},
+		{
+			name:     "version below the pending scripts",
+			approval: stringPtr("0.0.2"),
+			want:     []string{},
+		},
+		{
+			name:     "custom script approves the built-in ones",
+			approval: stringPtr("reporting:0.0.1"),
+			want:     []string{"events-database-update-0.0.3.sql", "events-database-update-0.0.4.sql", "reporting.views-0.0.1.sql"},
+		},
+		{
+			name:     "invalid version",
+			approval: stringPtr("latest"),
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := &gramolav1.AppService{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+			if test.approval != nil {
+				instance.Annotations[_deployment.MigrationApprovalAnnotation] = *test.approval
+			}
+
+			approvedScripts, err := approvedDatabaseScripts(instance, pendingScripts)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("approvedDatabaseScripts() error = %v, wantErr %t", err, test.wantErr)
+			}
+			var got []string
+			if approvedScripts != nil {
+				got = []string{}
+				for _, approvedScript := range approvedScripts {
+					got = append(got, approvedScript.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("approvedDatabaseScripts() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Reconciling Events Database pooler
+func (r *AppServiceReconciler) reconcilePooler(instance *gramolav1.AppService) (reconcile.Result, error) {
+
+	if _deployment.IsEventsDatabasePooled(instance) {
+		if result, err := r.addEventsDatabasePooler(instance); err != nil {
+			return result, err
+		}
+	} else {
+		if result, err := r.removeEventsDatabasePooler(instance); err != nil {
+			return result, err
+		}
+	}
+
+	// Success
+	return reconcile.Result{}, nil
+}
+
+// addEventsDatabasePooler creates or updates the pooler, the Events Deployment is pointed to it in the Events section
+// once it's ready
+func (r *AppServiceReconciler) addEventsDatabasePooler(instance *gramolav1.AppService) (reconcile.Result, error) {
+	// On a fresh install the credentials are generated in the Events section, the pooler follows once they exist
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.GetEventsDatabaseCredentialsSecretName(instance), Namespace: instance.Namespace}, &corev1.Secret{}); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	credentials, err := r.getEventsDatabaseCredentials(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// The configuration follows the credentials, so it's regenerated on every reconcile
+	var checksum string
+	if poolerSecret, err := _deployment.NewEventsDatabasePoolerSecret(instance, r.Scheme, credentials); err == nil {
+		checksum = _deployment.GetEventsDatabasePoolerConfigChecksum(poolerSecret.StringData)
+		if err := r.Client.Create(context.TODO(), poolerSecret); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				from := &corev1.Secret{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: poolerSecret.Name, Namespace: poolerSecret.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabasePoolerSecretPatch(from, poolerSecret.StringData)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Pooler Secret created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Secret", poolerSecret.Name))
+		r.Recorder.Eventf(instance, "Normal", "Secret Created/Updated", "Created/Updated %s Secret", poolerSecret.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	if poolerDeployment, err := _deployment.NewEventsDatabasePoolerDeployment(instance, r.Scheme, checksum); err == nil {
+		if err := r.Client.Create(context.TODO(), poolerDeployment); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				from := &appsv1.Deployment{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: poolerDeployment.Name, Namespace: poolerDeployment.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabasePoolerDeploymentPatch(from, instance, checksum)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Pooler Deployment created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Deployment", poolerDeployment.Name))
+		r.Recorder.Eventf(instance, "Normal", "Deployment Created/Updated", "Created/Updated %s Deployment", poolerDeployment.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	if poolerService, err := _deployment.NewEventsDatabasePoolerService(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), poolerService); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				from := &corev1.Service{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: poolerService.Name, Namespace: poolerService.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseServicePatch(from)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Pooler Service created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Service", poolerService.Name))
+		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", poolerService.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// removeEventsDatabasePooler deletes the pooler once no Events pod connects through it, the Events Deployment is
+// pointed back to the database in the Events section
+func (r *AppServiceReconciler) removeEventsDatabasePooler(instance *gramolav1.AppService) (reconcile.Result, error) {
+	events := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: instance.Namespace}, events); err == nil {
+		if _deployment.IsEventsDeploymentPooled(events) || events.Status.ObservedGeneration < events.Generation || events.Status.UpdatedReplicas < events.Status.Replicas {
+			return reconcile.Result{}, nil
+		}
+	} else if !k8s_errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	objects := []runtime.Object{
+		&appsv1.Deployment{},
+		&corev1.Service{},
+		&corev1.Secret{},
+	}
+	kinds := []string{
+		"Deployment",
+		"Service",
+		"Secret",
+	}
+	for i, object := range objects {
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabasePoolerName, Namespace: instance.Namespace}, object); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Delete(context.TODO(), object, client.PropagationPolicy("Background")); err != nil && !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		log.Info(fmt.Sprintf("Deleted %s %s", _deployment.EventsDatabasePoolerName, kinds[i]))
+		r.Recorder.Eventf(instance, "Normal", "Pooler Deleted", "Deleted %s %s", _deployment.EventsDatabasePoolerName, kinds[i])
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// isEventsDatabasePoolerInUse returns true if the Events Service has to connect through the pooler, that is once the
+// pooler is ready. Events stays on the pooler while it's enabled, so that a rollout of the pooler doesn't move it back
+func (r *AppServiceReconciler) isEventsDatabasePoolerInUse(instance *gramolav1.AppService) (bool, error) {
+	if !_deployment.IsEventsDatabasePooled(instance) {
+		return false, nil
+	}
+
+	events := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: instance.Namespace}, events); err == nil {
+		if _deployment.IsEventsDeploymentPooled(events) {
+			return true, nil
+		}
+	} else if !k8s_errors.IsNotFound(err) {
+		return false, err
+	}
+
+	pooler := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabasePoolerName, Namespace: instance.Namespace}, pooler); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return pooler.Status.ObservedGeneration >= pooler.Generation && pooler.Status.ReadyReplicas > 0, nil
+}
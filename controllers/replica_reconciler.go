@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Reconciling Events Database replicas
+func (r *AppServiceReconciler) reconcileReplicas(instance *gramolav1.AppService) (reconcile.Result, error) {
+
+	if _deployment.IsEventsDatabaseReplicated(instance) {
+		if result, err := r.addEventsDatabaseReplicas(instance); err != nil {
+			return result, err
+		}
+	} else {
+		if result, err := r.removeEventsDatabaseReplicas(instance); err != nil {
+			return result, err
+		}
+	}
+
+	if !_deployment.IsEventsDatabaseExternal(instance) {
+		if err := r.updateEventsDatabaseMembers(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Success
+	return reconcile.Result{}, nil
+}
+
+// addEventsDatabaseReplicationSecret creates the replication credentials, once generated they're never changed because
+// the primary only sets them on initialization
+func (r *AppServiceReconciler) addEventsDatabaseReplicationSecret(instance *gramolav1.AppService) error {
+	secret, err := _deployment.NewEventsDatabaseReplicationSecret(instance, r.Scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(context.TODO(), secret); err != nil {
+		if k8s_errors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	// Replication Secret created successfully
+	log.Info(fmt.Sprintf("Created %s Secret", secret.Name))
+	r.Recorder.Eventf(instance, "Normal", "Secret Created", "Created %s Secret", secret.Name)
+
+	return nil
+}
+
+func (r *AppServiceReconciler) addEventsDatabaseReplicas(instance *gramolav1.AppService) (reconcile.Result, error) {
+	if err := r.addEventsDatabaseReplicationSecret(instance); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if configMap, err := _deployment.NewEventsDatabasePromoteConfigMap(instance, r.Scheme); err == nil {
+		// Its keys are managed by failovers, so it's only created
+		if err := r.Client.Create(context.TODO(), configMap); err != nil && !k8s_errors.IsAlreadyExists(err) {
+			return reconcile.Result{}, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s ConfigMap", configMap.Name))
+			r.Recorder.Eventf(instance, "Normal", "ConfigMap Created", "Created %s ConfigMap", configMap.Name)
+		}
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	for _, newService := range []func(*gramolav1.AppService) (*corev1.Service, error){
+		func(instance *gramolav1.AppService) (*corev1.Service, error) {
+			return _deployment.NewEventsDatabaseReplicaHeadlessService(instance, r.Scheme)
+		},
+		func(instance *gramolav1.AppService) (*corev1.Service, error) {
+			return _deployment.NewEventsDatabaseReadOnlyService(instance, r.Scheme)
+		},
+	} {
+		if service, err := newService(instance); err == nil {
+			if err := r.Client.Create(context.TODO(), service); err != nil {
+				if k8s_errors.IsAlreadyExists(err) {
+					from := &corev1.Service{}
+					if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, from); err == nil {
+						patch := _deployment.NewEventsDatabaseServicePatch(from)
+						if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+							return reconcile.Result{}, err
+						}
+					}
+				} else {
+					return reconcile.Result{}, err
+				}
+			}
+			// Replicas Service created/updated successfully
+			log.Info(fmt.Sprintf("Created/Updated %s Service", service.Name))
+			r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", service.Name)
+		} else {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if statefulSet, err := _deployment.NewEventsDatabaseReplicaStatefulSet(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), statefulSet); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				from := &appsv1.StatefulSet{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: statefulSet.Name, Namespace: statefulSet.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseReplicaStatefulSetPatch(from, instance)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Replicas StatefulSet created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s StatefulSet", statefulSet.Name))
+		r.Recorder.Eventf(instance, "Normal", "StatefulSet Created/Updated", "Created/Updated %s StatefulSet", statefulSet.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	// Volume claim templates can't be changed, so the PVCs of the replicas are expanded directly along with the primary's
+	for ordinal := 0; ordinal < int(_deployment.GetEventsDatabaseReplicas(instance)); ordinal++ {
+		if err := r.expandEventsDatabaseReplicaPersistentVolumeClaim(instance, _deployment.EventsDatabaseReplicaPersistentVolumeClaimNameFor(ordinal)); err != nil && !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// expandEventsDatabaseReplicaPersistentVolumeClaim grows the given replica PVC to the size of the Events Database volume,
+// unlike the primary's its capacity isn't recorded in status
+func (r *AppServiceReconciler) expandEventsDatabaseReplicaPersistentVolumeClaim(instance *gramolav1.AppService, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, pvc); err != nil {
+		return err
+	}
+
+	return r.expandPersistentVolumeClaim(instance, pvc, _deployment.GetEventsDatabaseStorageSize(instance))
+}
+
+// removeEventsDatabaseReplicas deletes the replicas StatefulSet and its Services, their PVCs are kept
+func (r *AppServiceReconciler) removeEventsDatabaseReplicas(instance *gramolav1.AppService) (reconcile.Result, error) {
+	objects := []runtime.Object{
+		&appsv1.StatefulSet{},
+		&corev1.Service{},
+		&corev1.Service{},
+	}
+	names := []string{
+		_deployment.EventsDatabaseReplicaName,
+		_deployment.EventsDatabaseReplicaName,
+		_deployment.EventsDatabaseReadOnlyServiceName,
+	}
+	for i, object := range objects {
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: names[i], Namespace: instance.Namespace}, object); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			return reconcile.Result{}, err
+		}
+		if err := r.Client.Delete(context.TODO(), object, client.PropagationPolicy("Background")); err != nil && !k8s_errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		log.Info(fmt.Sprintf("Deleted %s", names[i]))
+		r.Recorder.Eventf(instance, "Normal", "Replicas Deleted", "Deleted %s", names[i])
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// updateEventsDatabaseMembers labels the Events Database pods with their role and records them in the status along
+// with how far behind the primary each replica is
+func (r *AppServiceReconciler) updateEventsDatabaseMembers(instance *gramolav1.AppService) error {
+	members := []gramolav1.DatabaseMemberStatus{}
+
+	for _, component := range []string{_deployment.EventsDatabaseServiceName, _deployment.EventsDatabaseReplicaName} {
+		role, roleLabel := gramolav1.DatabaseRolePrimary, _deployment.EventsDatabaseRolePrimary
+		if component == _deployment.EventsDatabaseReplicaName {
+			role, roleLabel = gramolav1.DatabaseRoleReplica, _deployment.EventsDatabaseRoleReplica
+		}
+
+		podList := &corev1.PodList{}
+		listOps := &client.ListOptions{Namespace: instance.Namespace, LabelSelector: labels.SelectorFromSet(map[string]string{"component": component})}
+		if err := r.Client.List(context.TODO(), podList, listOps); err != nil {
+			return err
+		}
+
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			podRole, podRoleLabel := role, roleLabel
+			if pod.Name == _deployment.GetEventsDatabasePromotedPrimary(instance) {
+				podRole, podRoleLabel = gramolav1.DatabaseRolePrimary, _deployment.EventsDatabaseRolePrimary
+			}
+			if pod.Labels[_deployment.EventsDatabaseRoleLabel] != podRoleLabel {
+				if err := r.Client.Patch(context.TODO(), pod, _deployment.NewEventsDatabasePodRolePatch(pod, podRoleLabel)); err != nil {
+					return err
+				}
+			}
+			members = append(members, gramolav1.DatabaseMemberStatus{
+				Pod:   pod.Name,
+				Role:  podRole,
+				Ready: isPodReady(pod),
+			})
+		}
+	}
+
+	if _deployment.IsEventsDatabaseReplicated(instance) {
+		r.updateEventsDatabaseReplicationLag(instance, members)
+	}
+
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	instance.Status.Database.Members = members
+
+	return nil
+}
+
+// updateEventsDatabaseReplicationLag sets the bytes of WAL each ready replica has yet to replay, a member that can't
+// be queried gets a message instead
+func (r *AppServiceReconciler) updateEventsDatabaseReplicationLag(instance *gramolav1.AppService, members []gramolav1.DatabaseMemberStatus) {
+	credentials, err := r.getEventsDatabaseCredentials(instance)
+	if err != nil {
+		log.Info(fmt.Sprintf("Replication lag not available: %s", err))
+		return
+	}
+
+	primary, err := connectEventsDatabase(instance, "", credentials)
+	if err == nil {
+		defer primary.Close()
+	}
+	var currentLSN string
+	if err == nil {
+		currentLSN, err = primary.CurrentWALPosition(context.TODO())
+	}
+	if err != nil {
+		for i := range members {
+			if members[i].Role == gramolav1.DatabaseRolePrimary {
+				members[i].Message = fmt.Sprintf("Unable to query the current WAL position: %s", err)
+			}
+		}
+		return
+	}
+
+	for i := range members {
+		member := &members[i]
+		if member.Role != gramolav1.DatabaseRoleReplica || !member.Ready {
+			continue
+		}
+
+		pod := &corev1.Pod{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: member.Pod, Namespace: instance.Namespace}, pod); err != nil {
+			member.Message = err.Error()
+			continue
+		}
+
+		lag, inRecovery, err := queryEventsDatabaseReplicationLag(instance, pod.Status.PodIP, credentials, currentLSN)
+		if err != nil {
+			member.Message = fmt.Sprintf("Unable to query the replication lag: %s", err)
+			continue
+		}
+		if !inRecovery {
+			member.Message = "Not in recovery, this replica isn't streaming from the primary"
+			continue
+		}
+		member.LagBytes = &lag
+	}
+}
+
+// queryEventsDatabaseReplicationLag returns the replication lag of the Events Database member in the given host
+func queryEventsDatabaseReplicationLag(instance *gramolav1.AppService, host string, credentials map[string]string, position string) (int64, bool, error) {
+	replica, err := connectEventsDatabase(instance, host, credentials)
+	if err != nil {
+		return 0, false, err
+	}
+	defer replica.Close()
+
+	return replica.ReplicationLag(context.TODO(), position)
+}
+
+// isPodReady returns true if the pod is running and ready
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
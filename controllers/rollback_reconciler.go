@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/atarazana/gramola-operator/database"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	batchv1 "k8s.io/api/batch/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// eventsDatabaseRollbackBackupVersion returns the version the backup taken before undoing the given version is named after
+func eventsDatabaseRollbackBackupVersion(version string) string {
+	return "rollback-" + version
+}
+
+// databaseScriptsUpTo returns, keeping the order, the built-in scripts that don't bring the schema above the given
+// version, custom scripts come after all of them
+func databaseScriptsUpTo(updateScripts []_deployment.EventsDatabaseUpdateScript, version string) []_deployment.EventsDatabaseUpdateScript {
+	maxVersion, err := semver.Parse(version)
+	if err != nil {
+		return nil
+	}
+
+	scripts := []_deployment.EventsDatabaseUpdateScript{}
+	for _, updateScript := range updateScripts {
+		if len(updateScript.ConfigMap) <= 0 && updateScript.Version.LTE(maxVersion) {
+			scripts = append(scripts, updateScript)
+		}
+	}
+
+	return scripts
+}
+
+// rollbackEventsDatabase undoes, newest first and one per call, every update script applied above the version set in
+// spec.database.rollbackTo, custom scripts included, returns true once there's none left. The database is backed up before each down script,
+// and a down script that fails leaves the schema as it was because it runs in a single transaction
+func (r *AppServiceReconciler) rollbackEventsDatabase(instance *gramolav1.AppService, updateScripts []_deployment.EventsDatabaseUpdateScript, target string) (bool, error) {
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	status := instance.Status.Database.Rollback
+	if status == nil || status.TargetVersion != target {
+		status = &gramolav1.DatabaseRollbackStatus{
+			TargetVersion: target,
+			Phase:         gramolav1.DatabaseOperationPhasePending,
+		}
+		instance.Status.Database.Rollback = status
+	}
+
+	targetVersion, err := semver.Parse(target)
+	if err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = fmt.Sprintf("Not a valid version: %s", err)
+		return false, errors.Errorf("spec.database.rollbackTo %s is not a valid version: %s", target, err)
+	}
+
+	var updateScript *_deployment.EventsDatabaseUpdateScript
+	for i := len(updateScripts) - 1; i >= 0; i-- {
+		if (len(updateScripts[i].ConfigMap) > 0 || updateScripts[i].Version.GT(targetVersion)) && r.DatabaseScriptWasRun(instance, updateScripts[i].Name) {
+			updateScript = &updateScripts[i]
+			break
+		}
+	}
+	if updateScript == nil {
+		if status.Phase != gramolav1.DatabaseOperationPhaseSucceeded {
+			log.Info(fmt.Sprintf("Rolled back %s to %s", _deployment.EventsDatabaseServiceName, target))
+			r.Recorder.Eventf(instance, "Normal", "Rollback Succeeded", "Rolled back %s to %s", _deployment.EventsDatabaseServiceName, target)
+		}
+		status.Phase = gramolav1.DatabaseOperationPhaseSucceeded
+		status.Message = ""
+		return true, nil
+	}
+
+	version := updateScript.TrackedVersion()
+	if len(updateScript.DownName) <= 0 {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = fmt.Sprintf("%s has no down script", updateScript.Name)
+		return false, errors.Errorf("Can't roll back %s to %s, %s has no down script", _deployment.EventsDatabaseServiceName, target, updateScript.Name)
+	}
+
+	// Backup the database before undoing the script, undo only if the backup succeeded
+	if backedUp, err := r.BackupEventsDatabase(instance, eventsDatabaseRollbackBackupVersion(version)); err != nil || !backedUp {
+		status.Phase = gramolav1.DatabaseOperationPhaseRunning
+		status.Message = fmt.Sprintf("Backing up before undoing %s", updateScript.Name)
+		return false, err
+	}
+
+	// Run the down script only if there's a database ready to run it
+	if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseRunning
+		status.Message = fmt.Sprintf("Waiting for %s to undo %s", _deployment.EventsDatabaseServiceName, updateScript.Name)
+		return false, err
+	}
+	credentials, err := r.getEventsDatabaseCredentials(instance)
+	if err != nil {
+		return false, err
+	}
+	script, err := _deployment.RenderEventsDatabaseDownScript(*updateScript, _deployment.NewEventsDatabaseScriptContext(instance, credentials))
+	if err != nil {
+		return false, err
+	}
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	log.Info(fmt.Sprintf("Running %s on %s", updateScript.DownName, _deployment.EventsDatabaseServiceName))
+	ctx, cancel := context.WithTimeout(context.TODO(), eventsDatabaseScriptTimeout)
+	defer cancel()
+	if err := db.RunDownScript(ctx, script, version); err == database.ErrMigrationLocked {
+		status.Phase = gramolav1.DatabaseOperationPhaseRunning
+		status.Message = fmt.Sprintf("Waiting to undo %s: %s", updateScript.Name, err)
+		return false, nil
+	} else if err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = fmt.Sprintf("Failed undoing %s: %s", updateScript.Name, err)
+		return false, errors.Errorf("Failed running script %s on %s: %s", updateScript.DownName, _deployment.EventsDatabaseServiceName, err)
+	}
+
+	for i := len(instance.Status.EventsDatabaseScriptRuns) - 1; i >= 0; i-- {
+		if instance.Status.EventsDatabaseScriptRuns[i].Script == updateScript.Name &&
+			instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusSucceeded {
+			instance.Status.EventsDatabaseScriptRuns[i].Status = gramolav1.DatabaseUpdateStatusRolledBack
+			break
+		}
+	}
+
+	// The update is backed up again if it's run later on
+	if err := r.deleteJobs(instance, _deployment.EventsDatabasePreUpdateBackupJobNameFor(version)); err != nil {
+		return false, err
+	}
+
+	status.Phase = gramolav1.DatabaseOperationPhaseRunning
+	status.Message = ""
+	log.Info(fmt.Sprintf("Undid %s", updateScript.Name))
+	r.Recorder.Eventf(instance, "Normal", "Script Rolled Back", "Ran %s to undo %s", updateScript.DownName, updateScript.Name)
+	return false, nil
+}
+
+// deleteJobs deletes the given Jobs along with their pods if they exist
+func (r *AppServiceReconciler) deleteJobs(instance *gramolav1.AppService, names ...string) error {
+	for _, name := range names {
+		job := &batchv1.Job{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, job); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if err := r.Client.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+		log.Info(fmt.Sprintf("Deleted %s Job", name))
+	}
+
+	return nil
+}
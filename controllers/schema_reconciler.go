@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+
+	"github.com/atarazana/gramola-operator/database"
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileSchemaVersion reads the operator_version table of the Events Database and brings the script runs in the
+// status in line with it, the table wins because the status may be lost, i.e. if the AppService is recreated over
+// a retained volume, or the database restored from a backup. Nothing is changed if the database is not ready
+func (r *AppServiceReconciler) reconcileSchemaVersion(instance *gramolav1.AppService, updateScripts []_deployment.EventsDatabaseUpdateScript, credentials map[string]string) error {
+	if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+		return err
+	}
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	appliedVersions, err := db.AppliedVersions(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	for _, updateScript := range updateScripts {
+		version := updateScript.TrackedVersion()
+		appliedVersion, applied := appliedVersions[version]
+		wasRun := r.DatabaseScriptWasRun(instance, updateScript.Name)
+
+		switch {
+		case applied && !wasRun:
+			log.Info(fmt.Sprintf("Found %s applied as %s in %s", updateScript.Name, version, _deployment.EventsDatabaseServiceName))
+			r.Recorder.Eventf(instance, "Normal", "Script Run Found", "Found %s applied in %s", updateScript.Name, _deployment.EventsDatabaseServiceName)
+			// Without a checksum the script is taken as it is now
+			r.SetDatabaseScriptRun(instance, &gramolav1.DatabaseScriptRun{
+				Script:   updateScript.Name,
+				Version:  version,
+				Checksum: appliedVersion.Checksum,
+				Status:   gramolav1.DatabaseUpdateStatusSucceeded,
+			})
+		case !applied && wasRun:
+			log.Info(fmt.Sprintf("%s not applied as %s in %s anymore", updateScript.Name, version, _deployment.EventsDatabaseServiceName))
+			r.Recorder.Eventf(instance, "Warning", "Script Run Missing", "%s is not applied in %s anymore, it will be run again", updateScript.Name, _deployment.EventsDatabaseServiceName)
+			for i := range instance.Status.EventsDatabaseScriptRuns {
+				if instance.Status.EventsDatabaseScriptRuns[i].Script == updateScript.Name &&
+					instance.Status.EventsDatabaseScriptRuns[i].Status == gramolav1.DatabaseUpdateStatusSucceeded {
+					instance.Status.EventsDatabaseScriptRuns[i].Status = gramolav1.DatabaseUpdateStatusRolledBack
+				}
+			}
+		}
+	}
+
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	instance.Status.Database.SchemaVersion = database.HighestVersion(appliedVersions)
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileSeedData loads the events set in spec.seedData into the events table once every built-in update script
+// is applied, returns true while waiting to load them. Events are loaded only if the table is empty and never again
+// once the seeding succeeded. In DryRun and Manual migration mode the seeding is blocked until the scripts are run
+func (r *AppServiceReconciler) reconcileSeedData(instance *gramolav1.AppService, updateScripts []_deployment.EventsDatabaseUpdateScript, credentials map[string]string) (bool, error) {
+	source := _deployment.GetSeedDataSource(instance)
+	if len(source) <= 0 {
+		return false, nil
+	}
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	status := instance.Status.Database.SeedData
+	if status != nil && status.Phase == gramolav1.DatabaseOperationPhaseSucceeded {
+		return false, nil
+	}
+	status = &gramolav1.SeedDataStatus{
+		Source: source,
+		Phase:  gramolav1.DatabaseOperationPhasePending,
+	}
+	instance.Status.Database.SeedData = status
+
+	// The events table has to be in its latest shape, the seeding follows the run of the last script. Scripts held by
+	// the migration mode don't change until the AppService does, which triggers a new reconcile
+	for _, updateScript := range r.PendingDatabaseScripts(instance, updateScripts) {
+		if len(updateScript.ConfigMap) <= 0 {
+			switch _deployment.GetMigrationMode(instance) {
+			case gramolav1.MigrationModeDryRun:
+				status.Message = fmt.Sprintf("Blocked until %s is run, which doesn't happen in %s migration mode", updateScript.Name, gramolav1.MigrationModeDryRun)
+			case gramolav1.MigrationModeManual:
+				status.Message = fmt.Sprintf("Blocked until %s is approved in the %s annotation", updateScript.Name, _deployment.MigrationApprovalAnnotation)
+			default:
+				status.Message = fmt.Sprintf("Waiting for %s to be run", updateScript.Name)
+			}
+			return false, nil
+		}
+	}
+	if ready, err := r.IsEventsDatabaseReady(instance); !ready || err != nil {
+		status.Message = fmt.Sprintf("Waiting for %s to be ready", _deployment.EventsDatabaseServiceName)
+		return err == nil, err
+	}
+
+	documents, err := r.getSeedDataDocuments(instance)
+	if err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = err.Error()
+		return false, err
+	}
+	names := []string{}
+	for name := range documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	events := []map[string]interface{}{}
+	for _, name := range names {
+		documentEvents, err := _deployment.ParseSeedEvents(documents[name], time.Now().Format("2006-01-02"))
+		if err != nil {
+			status.Phase = gramolav1.DatabaseOperationPhaseFailed
+			status.Message = fmt.Sprintf("%s is not a valid JSON document: %s", name, err)
+			return false, errors.Errorf("Seed data %s in %s is not a valid JSON document: %s", name, source, err)
+		}
+		events = append(events, documentEvents...)
+	}
+
+	db, err := connectEventsDatabase(instance, "", credentials)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	loaded, err := db.SeedEvents(context.TODO(), events)
+	if err != nil {
+		status.Phase = gramolav1.DatabaseOperationPhaseFailed
+		status.Message = err.Error()
+		return false, errors.Errorf("Failed seeding %s with %s: %s", _deployment.EventsDatabaseServiceName, source, err)
+	}
+
+	completionTime := metav1.Now()
+	status.Phase = gramolav1.DatabaseOperationPhaseSucceeded
+	status.Events = int32(loaded)
+	status.CompletionTime = &completionTime
+	status.Message = ""
+	if loaded <= 0 && len(events) > 0 {
+		status.Message = "The events table wasn't empty, nothing was loaded"
+	}
+	log.Info(fmt.Sprintf("Seeded %s with %d events from %s", _deployment.EventsDatabaseServiceName, loaded, source))
+	r.Recorder.Eventf(instance, "Normal", "Seed Data Loaded", "Loaded %d events from %s", loaded, source)
+
+	return false, nil
+}
+
+// getSeedDataDocuments returns the JSON documents with the seed data by name
+func (r *AppServiceReconciler) getSeedDataDocuments(instance *gramolav1.AppService) (map[string]string, error) {
+	if instance.Spec.SeedData.ConfigMap != nil && len(instance.Spec.SeedData.ConfigMap.Name) > 0 {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.SeedData.ConfigMap.Name, Namespace: instance.Namespace}, configMap); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return nil, errors.Errorf("Seed data ConfigMap %s not found", instance.Spec.SeedData.ConfigMap.Name)
+			}
+			return nil, err
+		}
+		return configMap.Data, nil
+	}
+
+	document, err := _deployment.GetSeedDataSampleSet(instance.Spec.SeedData.SampleSet)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{string(instance.Spec.SeedData.SampleSet): document}, nil
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// addEventsDatabaseStatefulSet runs the Events Database as a StatefulSet, moving the data of a previous Deployment first
+func (r *AppServiceReconciler) addEventsDatabaseStatefulSet(instance *gramolav1.AppService) (reconcile.Result, error) {
+	if headlessService, err := _deployment.NewEventsDatabaseHeadlessService(instance, r.Scheme); err == nil {
+		if err := r.Client.Create(context.TODO(), headlessService); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				from := &corev1.Service{}
+				if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: headlessService.Name, Namespace: headlessService.Namespace}, from); err == nil {
+					patch := _deployment.NewEventsDatabaseServicePatch(from)
+					if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+						return reconcile.Result{}, err
+					}
+				}
+			} else {
+				return reconcile.Result{}, err
+			}
+		}
+		// Events Database headless Service created/updated successfully
+		log.Info(fmt.Sprintf("Created/Updated %s Service", headlessService.Name))
+		r.Recorder.Eventf(instance, "Normal", "Service Created/Updated", "Created/Updated %s Service", headlessService.Name)
+	} else {
+		return reconcile.Result{}, err
+	}
+
+	if migrated, err := r.migrateEventsDatabaseToStatefulSet(instance); err != nil || !migrated {
+		return reconcile.Result{}, err
+	}
+
+	statefulSet, err := _deployment.NewEventsDatabaseStatefulSet(instance, r.Scheme)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.Client.Create(context.TODO(), statefulSet); err != nil {
+		if k8s_errors.IsAlreadyExists(err) {
+			from := &appsv1.StatefulSet{}
+			if err = r.Client.Get(context.TODO(), types.NamespacedName{Name: statefulSet.Name, Namespace: statefulSet.Namespace}, from); err == nil {
+				patch := _deployment.NewEventsDatabaseStatefulSetPatch(from, instance)
+				if err := r.Client.Patch(context.TODO(), from, patch); err != nil {
+					return reconcile.Result{}, err
+				}
+				statefulSet = from
+			}
+		} else {
+			return reconcile.Result{}, err
+		}
+	}
+	// Events Database StatefulSet created/updated successfully
+	log.Info(fmt.Sprintf("Created/Updated %s StatefulSet", statefulSet.Name))
+	r.Recorder.Eventf(instance, "Normal", "StatefulSet Created/Updated", "Created/Updated %s StatefulSet", statefulSet.Name)
+
+	// Volume claim templates can't be changed, so the PVC of the pod is expanded directly
+	if err := r.expandEventsDatabasePersistentVolumeClaim(instance, _deployment.EventsDatabaseStatefulSetPersistentVolumeClaimNameFor(0)); err != nil && !k8s_errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	setEventsDatabaseWorkload(instance, gramolav1.DatabaseWorkloadStatefulSet)
+
+	// The migration is over once the StatefulSet serves the copied data
+	if migration := instance.Status.Database.WorkloadMigration; migration != nil && migration.Phase == gramolav1.WorkloadMigrationPhaseSwitching && statefulSet.Status.ReadyReplicas > 0 {
+		completionTime := metav1.Now()
+		migration.Phase = gramolav1.WorkloadMigrationPhaseSucceeded
+		migration.Message = fmt.Sprintf("%s Persistent Volume Claim isn't used anymore and can be deleted", _deployment.GetEventsDatabasePersistentVolumeClaimName(instance))
+		migration.CompletionTime = &completionTime
+		r.Recorder.Eventf(instance, "Normal", "Migration Succeeded", "Moved %s to a StatefulSet", _deployment.EventsDatabaseServiceName)
+	}
+
+	//Success
+	return reconcile.Result{}, nil
+}
+
+// migrateEventsDatabaseToStatefulSet moves the data of an Events Database Deployment to the volume of the first pod of
+// the StatefulSet, returns true when there's no Deployment left. Every step is derived from the state of the Deployment
+// and the copy Job, so the migration resumes where it was if the operator restarts. A failed copy brings the
+// Deployment back and the migration is retried once the copy Job is deleted
+func (r *AppServiceReconciler) migrateEventsDatabaseToStatefulSet(instance *gramolav1.AppService) (bool, error) {
+	deployment := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}, deployment); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	job := &batchv1.Job{}
+	jobFound := true
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseStatefulSetMigrationJobName, Namespace: instance.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+		jobFound = false
+	}
+
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	status := instance.Status.Database.WorkloadMigration
+	if status == nil || status.Phase == gramolav1.WorkloadMigrationPhaseSucceeded || (status.Phase == gramolav1.WorkloadMigrationPhaseFailed && !jobFound) {
+		startTime := metav1.Now()
+		status = &gramolav1.WorkloadMigrationStatus{
+			Phase:     gramolav1.WorkloadMigrationPhaseScalingDown,
+			Job:       _deployment.EventsDatabaseStatefulSetMigrationJobName,
+			StartTime: &startTime,
+		}
+		instance.Status.Database.WorkloadMigration = status
+		r.Recorder.Eventf(instance, "Normal", "Migration Started", "Moving %s from a Deployment to a StatefulSet", _deployment.EventsDatabaseServiceName)
+	}
+
+	if jobFound {
+		if condition := getJobFailedCondition(job); condition != nil {
+			if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+				if err := r.Client.Patch(context.TODO(), deployment, _deployment.NewEventsDatabaseDeploymentScalePatch(deployment, _deployment.GetEventsDatabasePrimaryReplicas(instance))); err != nil {
+					return false, err
+				}
+			}
+			status.Phase = gramolav1.WorkloadMigrationPhaseFailed
+			status.Message = fmt.Sprintf("Delete %s Job to retry: %s", job.Name, condition.Message)
+			return false, errors.Errorf("Job %s failed to copy %s data to the StatefulSet: %s", job.Name, _deployment.EventsDatabaseServiceName, condition.Message)
+		}
+	}
+
+	// Stop the database so that the copy is consistent
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0 {
+		if err := r.Client.Patch(context.TODO(), deployment, _deployment.NewEventsDatabaseDeploymentScalePatch(deployment, 0)); err != nil {
+			return false, err
+		}
+		status.Phase = gramolav1.WorkloadMigrationPhaseScalingDown
+		status.Message = "The Events Database is not available during the migration"
+		log.Info(fmt.Sprintf("Scaled down %s Deployment to migrate it to a StatefulSet", deployment.Name))
+		return false, nil
+	}
+	if deployment.Status.Replicas > 0 {
+		return false, nil
+	}
+
+	if !jobFound {
+		pvc := _deployment.NewEventsDatabaseStatefulSetPersistentVolumeClaim(instance, 0)
+		if err := r.Client.Create(context.TODO(), pvc); err != nil && !k8s_errors.IsAlreadyExists(err) {
+			return false, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", pvc.Name))
+			r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", pvc.Name)
+		}
+
+		newJob, err := _deployment.NewEventsDatabaseStatefulSetMigrationJob(instance, r.Scheme)
+		if err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(context.TODO(), newJob); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", newJob.Name))
+		r.Recorder.Eventf(instance, "Normal", "Job Created", "Created %s Job to copy %s data", newJob.Name, _deployment.EventsDatabaseServiceName)
+		status.Phase = gramolav1.WorkloadMigrationPhaseCopyingData
+		return false, nil
+	}
+
+	if job.Status.Succeeded == 0 {
+		status.Phase = gramolav1.WorkloadMigrationPhaseCopyingData
+		return false, nil
+	}
+
+	// Data copied, the Deployment makes room for the StatefulSet while its PVC is kept just in case
+	if err := r.Client.Delete(context.TODO(), deployment, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !k8s_errors.IsNotFound(err) {
+		return false, err
+	}
+	log.Info(fmt.Sprintf("Deleted %s Deployment", deployment.Name))
+	r.Recorder.Eventf(instance, "Normal", "Deployment Deleted", "Deleted %s Deployment, its data was copied to %s", deployment.Name, _deployment.EventsDatabaseStatefulSetPersistentVolumeClaimNameFor(0))
+	status.Phase = gramolav1.WorkloadMigrationPhaseSwitching
+	status.Message = ""
+
+	return true, nil
+}
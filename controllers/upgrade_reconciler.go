@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	_deployment "github.com/atarazana/gramola-operator/deployment"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// reconcileEventsDatabaseVersion upgrades the Events Database to the major version set in spec.database.version, returns
+// true while an upgrade is in progress. Events is scaled down, the database is dumped into a new PVC served by the new
+// version and the Deployment is switched to it. The previous PVC is kept until the upgrade is confirmed with the
+// gramola.atarazana.com/confirm-database-upgrade annotation, setting the previous version back rolls the upgrade back
+func (r *AppServiceReconciler) reconcileEventsDatabaseVersion(instance *gramolav1.AppService) (bool, error) {
+	if _deployment.IsEventsDatabaseExternal(instance) {
+		return false, nil
+	}
+	if instance.Status.Database == nil {
+		instance.Status.Database = &gramolav1.DatabaseStatus{}
+	}
+	desired := _deployment.GetEventsDatabaseVersion(instance)
+
+	// The version that runs is the one of the image of the database
+	var template *corev1.PodTemplateSpec
+	deployment := &appsv1.Deployment{}
+	if _deployment.IsEventsDatabaseStatefulSet(instance) {
+		statefulSet := &appsv1.StatefulSet{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}, statefulSet); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		template = &statefulSet.Spec.Template
+		deployment = nil
+	} else {
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsDatabaseServiceName, Namespace: instance.Namespace}, deployment); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		template = &deployment.Spec.Template
+	}
+	current := _deployment.GetEventsDatabaseImageVersion(_deployment.GetEventsDatabaseContainerImage(template))
+	if len(current) <= 0 {
+		return false, nil
+	}
+	instance.Status.Database.Version = current
+
+	upgrade := instance.Status.Database.Upgrade
+	if current == desired {
+		if upgrade == nil {
+			return false, nil
+		}
+		switch upgrade.Phase {
+		case gramolav1.DatabaseUpgradePhaseScalingDown, gramolav1.DatabaseUpgradePhaseRestoring:
+			return false, r.rollBackEventsDatabaseUpgrade(instance, deployment, fmt.Sprintf("Upgrade to %s cancelled", upgrade.ToVersion))
+		case gramolav1.DatabaseUpgradePhaseSwitching:
+			if deployment == nil {
+				return false, nil
+			}
+			return r.switchEventsDatabaseUpgrade(instance, deployment)
+		case gramolav1.DatabaseUpgradePhaseAwaitingConfirmation:
+			if instance.Annotations[_deployment.DatabaseUpgradeConfirmAnnotation] != upgrade.ToVersion {
+				return false, nil
+			}
+			return false, r.confirmEventsDatabaseUpgrade(instance)
+		}
+		return false, nil
+	}
+
+	// Going back to the previous version is only possible while its volume is kept
+	if upgrade != nil && upgrade.Phase == gramolav1.DatabaseUpgradePhaseAwaitingConfirmation && desired == upgrade.FromVersion && deployment != nil {
+		return false, r.rollBackEventsDatabaseUpgrade(instance, deployment, fmt.Sprintf("Rolled back to %s, data written after the upgrade is lost", upgrade.FromVersion))
+	}
+
+	if reason := getEventsDatabaseUpgradeUnsupportedReason(instance, current, desired); len(reason) > 0 {
+		// An upgrade waiting for confirmation keeps its volume until it's confirmed or rolled back
+		if upgrade != nil && (upgrade.Phase == gramolav1.DatabaseUpgradePhaseAwaitingConfirmation ||
+			(upgrade.Phase == gramolav1.DatabaseUpgradePhaseFailed && upgrade.ToVersion == desired && upgrade.Message == reason)) {
+			return false, nil
+		}
+		startTime := metav1.Now()
+		instance.Status.Database.Upgrade = &gramolav1.DatabaseUpgradeStatus{
+			FromVersion:                   current,
+			ToVersion:                     desired,
+			Phase:                         gramolav1.DatabaseUpgradePhaseFailed,
+			Message:                       reason,
+			PreviousPersistentVolumeClaim: _deployment.GetEventsDatabasePersistentVolumeClaimName(instance),
+			StartTime:                     &startTime,
+			CompletionTime:                &startTime,
+		}
+		r.Recorder.Eventf(instance, "Warning", "Upgrade Failed", "Can't upgrade %s from %s to %s: %s", _deployment.EventsDatabaseServiceName, current, desired, reason)
+		return false, nil
+	}
+
+	job := &batchv1.Job{}
+	jobName := _deployment.EventsDatabaseUpgradeJobNameFor(desired)
+	jobFound := true
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: instance.Namespace}, job); err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return false, err
+		}
+		jobFound = false
+	}
+
+	// Start tracking a new upgrade, a failed one is retried once its Job is deleted
+	if upgrade == nil || upgrade.ToVersion != desired || upgrade.Job != jobName || upgrade.Phase == gramolav1.DatabaseUpgradePhaseSucceeded ||
+		upgrade.Phase == gramolav1.DatabaseUpgradePhaseRolledBack || (upgrade.Phase == gramolav1.DatabaseUpgradePhaseFailed && !jobFound) {
+		startTime := metav1.Now()
+		upgrade = &gramolav1.DatabaseUpgradeStatus{
+			FromVersion:                   current,
+			ToVersion:                     desired,
+			Phase:                         gramolav1.DatabaseUpgradePhaseScalingDown,
+			Job:                           jobName,
+			PersistentVolumeClaim:         _deployment.EventsDatabaseUpgradePersistentVolumeClaimNameFor(desired),
+			PreviousPersistentVolumeClaim: _deployment.GetEventsDatabasePersistentVolumeClaimName(instance),
+			StartTime:                     &startTime,
+		}
+		instance.Status.Database.Upgrade = upgrade
+		r.Recorder.Eventf(instance, "Normal", "Upgrade Started", "Upgrading %s from %s to %s", _deployment.EventsDatabaseServiceName, current, desired)
+	}
+
+	if jobFound {
+		if condition := getJobFailedCondition(job); condition != nil {
+			if err := r.scaleEventsBackAfterUpgrade(instance); err != nil {
+				return false, err
+			}
+			upgrade.Phase = gramolav1.DatabaseUpgradePhaseFailed
+			upgrade.Message = fmt.Sprintf("Delete %s Job to retry: %s", job.Name, condition.Message)
+			return false, errors.Errorf("Job %s failed to upgrade %s to %s: %s", job.Name, _deployment.EventsDatabaseServiceName, desired, condition.Message)
+		}
+	}
+
+	// Scale down Events so that nothing is written to the database after the dump
+	if scaledDown, err := r.scaleDownEventsForUpgrade(instance); err != nil || !scaledDown {
+		if err == nil {
+			upgrade.Phase = gramolav1.DatabaseUpgradePhaseScalingDown
+			upgrade.Message = fmt.Sprintf("Waiting for %s to scale down", _deployment.EventsServiceName)
+		}
+		return err == nil, err
+	}
+
+	if !jobFound {
+		pvc, err := _deployment.NewEventsDatabaseUpgradePersistentVolumeClaim(instance, r.Scheme, desired)
+		if err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(context.TODO(), pvc); err != nil && !k8s_errors.IsAlreadyExists(err) {
+			return false, err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Created %s Persistent Volume Claim", pvc.Name))
+			r.Recorder.Eventf(instance, "Normal", "PVC Created", "Created %s Persistent Volume Claim", pvc.Name)
+		}
+
+		newJob, err := _deployment.NewEventsDatabaseUpgradeJob(instance, r.Scheme, desired, pvc.Name)
+		if err != nil {
+			return false, err
+		}
+		if err := r.Client.Create(context.TODO(), newJob); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("Created %s Job", newJob.Name))
+		r.Recorder.Eventf(instance, "Normal", "Job Created", "Created %s Job to restore %s data into %s", newJob.Name, _deployment.EventsDatabaseServiceName, pvc.Name)
+		upgrade.Phase = gramolav1.DatabaseUpgradePhaseRestoring
+		upgrade.Message = fmt.Sprintf("%s is not available during the upgrade", _deployment.EventsServiceName)
+		return true, nil
+	}
+
+	if job.Status.Succeeded == 0 {
+		upgrade.Phase = gramolav1.DatabaseUpgradePhaseRestoring
+		return true, nil
+	}
+
+	// Data restored, the database restarts with the new version on the new volume
+	if err := r.Client.Patch(context.TODO(), deployment, _deployment.NewEventsDatabaseUpgradePatch(deployment, _deployment.EventsDatabaseImages[desired], upgrade.PersistentVolumeClaim)); err != nil {
+		return false, err
+	}
+	log.Info(fmt.Sprintf("Switched %s Deployment to %s", deployment.Name, upgrade.PersistentVolumeClaim))
+	r.Recorder.Eventf(instance, "Normal", "Deployment Switched", "Switched %s Deployment to version %s on %s", deployment.Name, desired, upgrade.PersistentVolumeClaim)
+	upgrade.Phase = gramolav1.DatabaseUpgradePhaseSwitching
+	upgrade.Message = fmt.Sprintf("Waiting for %s to be ready", deployment.Name)
+
+	return true, nil
+}
+
+// getEventsDatabaseUpgradeUnsupportedReason returns why the Events Database can't be moved between the given versions,
+// empty if it can
+func getEventsDatabaseUpgradeUnsupportedReason(instance *gramolav1.AppService, from string, to string) string {
+	if _deployment.IsEventsDatabaseStatefulSet(instance) {
+		return "upgrades aren't supported when the database runs as a StatefulSet"
+	}
+	if _deployment.IsEventsDatabaseReplicated(instance) {
+		return "upgrades aren't supported while the database is replicated"
+	}
+	fromVersion, err := strconv.Atoi(from)
+	if err != nil {
+		return fmt.Sprintf("unknown version %s", from)
+	}
+	toVersion, err := strconv.Atoi(to)
+	if err != nil {
+		return fmt.Sprintf("unknown version %s", to)
+	}
+	if toVersion < fromVersion {
+		return "downgrades aren't supported"
+	}
+	return ""
+}
+
+// switchEventsDatabaseUpgrade waits for the upgraded database to be ready, then brings Events back and waits for the
+// upgrade to be confirmed
+func (r *AppServiceReconciler) switchEventsDatabaseUpgrade(instance *gramolav1.AppService, deployment *appsv1.Deployment) (bool, error) {
+	upgrade := instance.Status.Database.Upgrade
+	if deployment.Status.ObservedGeneration < deployment.Generation || deployment.Status.UpdatedReplicas <= 0 || deployment.Status.ReadyReplicas <= 0 {
+		return true, nil
+	}
+
+	if err := r.scaleEventsBackAfterUpgrade(instance); err != nil {
+		return false, err
+	}
+	upgrade.Phase = gramolav1.DatabaseUpgradePhaseAwaitingConfirmation
+	upgrade.Message = fmt.Sprintf("Set annotation %s to %s to delete %s Persistent Volume Claim, or set version %s back to roll back",
+		_deployment.DatabaseUpgradeConfirmAnnotation, upgrade.ToVersion, upgrade.PreviousPersistentVolumeClaim, upgrade.FromVersion)
+	r.Recorder.Eventf(instance, "Normal", "Upgrade Switched", "%s runs version %s, %s", _deployment.EventsDatabaseServiceName, upgrade.ToVersion, upgrade.Message)
+
+	return false, nil
+}
+
+// confirmEventsDatabaseUpgrade deletes the volume and the Job left behind by an upgrade
+func (r *AppServiceReconciler) confirmEventsDatabaseUpgrade(instance *gramolav1.AppService) error {
+	upgrade := instance.Status.Database.Upgrade
+	if err := r.deleteEventsDatabaseUpgradeResources(instance, upgrade.Job, upgrade.PreviousPersistentVolumeClaim); err != nil {
+		return err
+	}
+
+	completionTime := metav1.Now()
+	upgrade.Phase = gramolav1.DatabaseUpgradePhaseSucceeded
+	upgrade.Message = ""
+	upgrade.CompletionTime = &completionTime
+	r.Recorder.Eventf(instance, "Normal", "Upgrade Succeeded", "Upgraded %s from %s to %s", _deployment.EventsDatabaseServiceName, upgrade.FromVersion, upgrade.ToVersion)
+
+	return nil
+}
+
+// rollBackEventsDatabaseUpgrade puts the Events Database back on the previous version and volume, if it was switched,
+// brings Events back and deletes the volume and the Job of the upgrade
+func (r *AppServiceReconciler) rollBackEventsDatabaseUpgrade(instance *gramolav1.AppService, deployment *appsv1.Deployment, message string) error {
+	upgrade := instance.Status.Database.Upgrade
+	if deployment != nil && upgrade.Phase == gramolav1.DatabaseUpgradePhaseAwaitingConfirmation {
+		if err := r.Client.Patch(context.TODO(), deployment, _deployment.NewEventsDatabaseUpgradePatch(deployment, _deployment.EventsDatabaseImages[upgrade.FromVersion], upgrade.PreviousPersistentVolumeClaim)); err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("Switched %s Deployment back to %s", deployment.Name, upgrade.PreviousPersistentVolumeClaim))
+	}
+	if err := r.scaleEventsBackAfterUpgrade(instance); err != nil {
+		return err
+	}
+	if err := r.deleteEventsDatabaseUpgradeResources(instance, upgrade.Job, upgrade.PersistentVolumeClaim); err != nil {
+		return err
+	}
+
+	completionTime := metav1.Now()
+	upgrade.Phase = gramolav1.DatabaseUpgradePhaseRolledBack
+	upgrade.Message = message
+	upgrade.CompletionTime = &completionTime
+	r.Recorder.Eventf(instance, "Normal", "Upgrade Rolled Back", "%s: %s", _deployment.EventsDatabaseServiceName, message)
+
+	return nil
+}
+
+// deleteEventsDatabaseUpgradeResources deletes the given upgrade Job and PVC, the PVC is only released once no pod uses it
+func (r *AppServiceReconciler) deleteEventsDatabaseUpgradeResources(instance *gramolav1.AppService, jobName string, claimName string) error {
+	if len(jobName) > 0 {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: instance.Namespace}}
+		if err := r.Client.Delete(context.TODO(), job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+	}
+	if len(claimName) > 0 {
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: claimName, Namespace: instance.Namespace}}
+		if err := r.Client.Delete(context.TODO(), pvc); err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		} else if err == nil {
+			log.Info(fmt.Sprintf("Deleted %s Persistent Volume Claim", claimName))
+			r.Recorder.Eventf(instance, "Normal", "PVC Deleted", "Deleted %s Persistent Volume Claim", claimName)
+		}
+	}
+
+	return nil
+}
+
+// scaleDownEventsForUpgrade scales Events down to zero recording the replicas it had, returns true once no pod is left
+func (r *AppServiceReconciler) scaleDownEventsForUpgrade(instance *gramolav1.AppService) (bool, error) {
+	upgrade := instance.Status.Database.Upgrade
+	events := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: instance.Namespace}, events); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if _, found := events.Annotations[_deployment.DatabaseUpgradeAnnotation]; !found {
+		patch := client.MergeFrom(events.DeepCopy())
+		if upgrade.EventsReplicas == nil {
+			replicas := _deployment.EventsServiceReplicas
+			if events.Spec.Replicas != nil {
+				replicas = *events.Spec.Replicas
+			}
+			upgrade.EventsReplicas = &replicas
+		}
+		if events.Annotations == nil {
+			events.Annotations = map[string]string{}
+		}
+		events.Annotations[_deployment.DatabaseUpgradeAnnotation] = upgrade.ToVersion
+		zero := int32(0)
+		events.Spec.Replicas = &zero
+		if err := r.Client.Patch(context.TODO(), events, patch); err != nil {
+			return false, err
+		}
+		r.Recorder.Eventf(instance, "Normal", "Scaled Down", "Scaled down %s Deployment to upgrade %s to %s", events.Name, _deployment.EventsDatabaseServiceName, upgrade.ToVersion)
+		return false, nil
+	}
+
+	return events.Status.Replicas <= 0, nil
+}
+
+// scaleEventsBackAfterUpgrade brings the Events Deployment back to the replicas it had before the upgrade
+func (r *AppServiceReconciler) scaleEventsBackAfterUpgrade(instance *gramolav1.AppService) error {
+	upgrade := instance.Status.Database.Upgrade
+	events := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: _deployment.EventsServiceName, Namespace: instance.Namespace}, events); err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if _, found := events.Annotations[_deployment.DatabaseUpgradeAnnotation]; !found {
+		return nil
+	}
+
+	patch := client.MergeFrom(events.DeepCopy())
+	delete(events.Annotations, _deployment.DatabaseUpgradeAnnotation)
+	if upgrade.EventsReplicas != nil {
+		events.Spec.Replicas = upgrade.EventsReplicas
+	} else {
+		events.Spec.Replicas = &_deployment.EventsServiceReplicas
+	}
+	if err := r.Client.Patch(context.TODO(), events, patch); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(instance, "Normal", "Scaled Up", "Scaled %s Deployment back to %d replicas", events.Name, *events.Spec.Replicas)
+
+	return nil
+}
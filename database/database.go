@@ -0,0 +1,523 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/lib/pq"
+)
+
+// DefaultConnectTimeout seconds to wait for a connection to the database
+const DefaultConnectTimeout = 5
+
+// MigrationLockKeyPrefix is prepended to the version a script brings the schema to or from to get the key of the
+// advisory lock taken while the script runs. Other tools can hold off migrating the database by taking the same
+// lock, i.e. SELECT pg_advisory_lock(hashtext('operator_version:0.0.2'))
+const MigrationLockKeyPrefix = "operator_version:"
+
+// ErrMigrationLocked is returned when someone else holds the lock of the version being migrated
+var ErrMigrationLocked = errors.New("another migration of the same version is in progress")
+
+// Config holds what is needed to connect to a PostgreSQL database
+type Config struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+
+	// SSLMode as in libpq, lib/pq requires TLS if not set
+	SSLMode string
+
+	// SSLRootCert is the PEM certificate of the authority that signed the certificate of the server, if set
+	SSLRootCert string
+
+	// ConnectTimeout in seconds, DefaultConnectTimeout if not set
+	ConnectTimeout int
+}
+
+// Client runs statements against a PostgreSQL database
+type Client struct {
+	db *sql.DB
+
+	// dsn the connections are opened with
+	dsn string
+}
+
+// ScriptError is the error reported by the database when a script fails
+type ScriptError struct {
+	// SQLState is the PostgreSQL error code
+	SQLState string
+
+	// Message of the error
+	Message string
+
+	// Line of the script where the failing statement is, 0 if unknown
+	Line int
+
+	// Detail of the error if the database gave any
+	Detail string
+
+	// Hint to fix the error if the database gave any
+	Hint string
+}
+
+// Error returns the error as a single line
+func (e *ScriptError) Error() string {
+	message := e.Message
+	if len(e.SQLState) > 0 {
+		message = e.SQLState + ": " + message
+	}
+	if e.Line > 0 {
+		message = fmt.Sprintf("line %d: %s", e.Line, message)
+	}
+	if len(e.Detail) > 0 {
+		message += " (" + e.Detail + ")"
+	}
+	return message
+}
+
+// dataSourceName returns the URL libpq understands for the given configuration
+func dataSourceName(config Config) string {
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = DefaultConnectTimeout
+	}
+	query := url.Values{}
+	query.Set("connect_timeout", strconv.Itoa(connectTimeout))
+	if len(config.SSLMode) > 0 {
+		query.Set("sslmode", config.SSLMode)
+	}
+	// lib/pq reads the certificate from the value itself rather than from a file
+	if len(config.SSLRootCert) > 0 {
+		query.Set("sslrootcert", config.SSLRootCert)
+		query.Set("sslinline", "true")
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Path:     config.Database,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
+
+// Connect returns a Client connected to the database, the connection is checked before returning
+func Connect(ctx context.Context, config Config) (*Client, error) {
+	dsn := dataSourceName(config)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{db: db, dsn: dsn}
+	if err := client.Ping(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Close closes every connection to the database
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// Ping checks the database takes connections and answers queries
+func (c *Client) Ping(ctx context.Context) error {
+	var one int
+	return c.db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// RunScript runs the given script in a single transaction, a failing statement rolls back the whole script and
+// the error is returned as a *ScriptError
+func (c *Client) RunScript(ctx context.Context, script string) error {
+	return c.runInTransaction(ctx, script, "", "", nil)
+}
+
+// RunUpdateScript runs the given update script in a single transaction along with recording it in the
+// operator_version row of the version it updates the schema to, built-in scripts add the row themselves. The
+// migration lock of the version is held until the transaction ends, ErrMigrationLocked is returned if it's taken,
+// and the script isn't run again if the version was already recorded with the same checksum while waiting for it
+func (c *Client) RunUpdateScript(ctx context.Context, name string, script string, version string, checksum string) error {
+	return c.runInTransaction(ctx, script, version, checksum, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO public.operator_version (version, start_time, end_time, script_name, run_count) "+
+			"VALUES ($1, LOCALTIME, LOCALTIME, left($2, 50), 1) ON CONFLICT (version) DO NOTHING", version, name); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "ALTER TABLE public.operator_version ADD COLUMN IF NOT EXISTS checksum CHARACTER VARYING(64)"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE public.operator_version SET checksum = $1 WHERE version = $2", checksum, version)
+		return err
+	})
+}
+
+// RunDownScript runs the given down script in a single transaction along with removing the operator_version row of
+// the version it brings the schema back from. The migration lock of the version is held until the transaction ends,
+// ErrMigrationLocked is returned if it's taken
+func (c *Client) RunDownScript(ctx context.Context, script string, version string) error {
+	return c.runInTransaction(ctx, script, version, "", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM public.operator_version WHERE version = $1", version)
+		return err
+	})
+}
+
+// runInTransaction runs the script and then the given function in the same transaction, holding the migration lock
+// of the version if set. If a checksum is given and the version is already recorded with it the script is skipped
+func (c *Client) runInTransaction(ctx context.Context, script string, version string, checksum string, after func(tx *sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(version) > 0 {
+		var locked bool
+		if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock(hashtext($1))", MigrationLockKeyPrefix+version).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return ErrMigrationLocked
+		}
+		if len(checksum) > 0 {
+			if applied, err := isVersionApplied(ctx, tx, version, checksum); err != nil || applied {
+				return err
+			}
+		}
+	}
+
+	// Without arguments the script is sent as a simple query, so it may hold many statements
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return newScriptError(err, script)
+	}
+	if after != nil {
+		if err := after(tx); err != nil {
+			return newScriptError(err, "")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DryRunResult is the outcome of a dry run of scripts
+type DryRunResult struct {
+	// Notices and warnings raised by the scripts
+	Notices []string
+
+	// AffectedTables lists, sorted, the tables whose rows or definition the scripts changed
+	AffectedTables []string
+
+	// FailedScript is the index of the script that failed, -1 if they all run with success
+	FailedScript int
+}
+
+// DryRunScripts runs the given scripts in order in a single transaction that is always rolled back, the result tells
+// the notices they raised and the tables they changed. The first failing script stops the run and its error is
+// returned as a *ScriptError
+func (c *Client) DryRunScripts(ctx context.Context, scripts []string) (*DryRunResult, error) {
+	result := &DryRunResult{FailedScript: -1}
+
+	// Notices are only reported to a handler of the connection, so the dry run gets one of its own
+	connector, err := pq.NewConnector(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(pq.ConnectorWithNoticeHandler(connector, func(notice *pq.Error) {
+		result.Notices = append(result.Notices, notice.Severity+": "+notice.Message)
+	}))
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, script := range scripts {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			result.FailedScript = i
+			return result, newScriptError(err, script)
+		}
+	}
+
+	// Tables created or altered in the transaction have its id as the xmin of their pg_class row
+	rows, err := tx.QueryContext(ctx, "SELECT relname FROM pg_stat_xact_user_tables WHERE n_tup_ins + n_tup_upd + n_tup_del > 0 "+
+		"UNION SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace "+
+		"WHERE n.nspname = current_schema() AND c.relkind = 'r' AND c.xmin::text::bigint = txid_current() % 4294967296 ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		result.AffectedTables = append(result.AffectedTables, table)
+	}
+
+	return result, rows.Err()
+}
+
+// isVersionApplied returns true if the version is recorded in operator_version with the given checksum
+func isVersionApplied(ctx context.Context, tx *sql.Tx, version string, checksum string) (bool, error) {
+	var applied bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.columns "+
+		"WHERE table_schema = 'public' AND table_name = 'operator_version' AND column_name = 'checksum')").Scan(&applied)
+	if err != nil || !applied {
+		return false, err
+	}
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM public.operator_version WHERE version = $1 AND checksum = $2)", version, checksum).Scan(&applied)
+	return applied, err
+}
+
+// newScriptError returns the error reported by the database as a *ScriptError, other errors are returned as they are
+func newScriptError(err error, script string) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return err
+	}
+
+	scriptError := &ScriptError{
+		SQLState: string(pqErr.Code),
+		Message:  pqErr.Message,
+		Detail:   pqErr.Detail,
+		Hint:     pqErr.Hint,
+	}
+	// The position is counted in characters from 1
+	if position, err := strconv.Atoi(pqErr.Position); err == nil && position > 0 {
+		runes := []rune(script)
+		if position > len(runes) {
+			position = len(runes)
+		}
+		scriptError.Line = strings.Count(string(runes[:position-1]), "\n") + 1
+	}
+
+	return scriptError
+}
+
+// AppliedVersion is a row of the operator_version table, written by every update script once it's run
+type AppliedVersion struct {
+	Version    string
+	ScriptName string
+	RunCount   int
+
+	// Checksum of the script as it was run, empty if it was run before checksums were recorded
+	Checksum string
+}
+
+// AppliedVersions returns the rows of the operator_version table by version, none if the table isn't there yet
+func (c *Client) AppliedVersions(ctx context.Context) (map[string]AppliedVersion, error) {
+	var table sql.NullString
+	if err := c.db.QueryRowContext(ctx, "SELECT to_regclass('public.operator_version')::text").Scan(&table); err != nil {
+		return nil, err
+	}
+	appliedVersions := map[string]AppliedVersion{}
+	if !table.Valid {
+		return appliedVersions, nil
+	}
+
+	// The checksum column is added by the operator the first time it runs a script
+	rows, err := c.db.QueryContext(ctx, "SELECT version, script_name, run_count, COALESCE(to_jsonb(v)->>'checksum', '') FROM public.operator_version v")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var appliedVersion AppliedVersion
+		if err := rows.Scan(&appliedVersion.Version, &appliedVersion.ScriptName, &appliedVersion.RunCount, &appliedVersion.Checksum); err != nil {
+			return nil, err
+		}
+		appliedVersions[appliedVersion.Version] = appliedVersion
+	}
+
+	return appliedVersions, rows.Err()
+}
+
+// SchemaVersion returns the highest version recorded in the operator_version table, empty if there's none yet
+func (c *Client) SchemaVersion(ctx context.Context) (string, error) {
+	appliedVersions, err := c.AppliedVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return HighestVersion(appliedVersions), nil
+}
+
+// HighestVersion returns the highest of the applied versions, empty if there's none
+func HighestVersion(appliedVersions map[string]AppliedVersion) string {
+	var highest *semver.Version
+	for version := range appliedVersions {
+		parsed, err := semver.Parse(version)
+		if err != nil {
+			continue
+		}
+		if highest == nil || parsed.GT(*highest) {
+			highest = &parsed
+		}
+	}
+	if highest == nil {
+		return ""
+	}
+
+	return highest.String()
+}
+
+// SeedEvents inserts the given events, keyed by the columns of the event table, in a single transaction only if the
+// event table is empty, returns how many were inserted. Keys with no column are ignored
+func (c *Client) SeedEvents(ctx context.Context, events []map[string]interface{}) (int, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Nobody else can add events until the seeding is done
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE public.event IN EXCLUSIVE MODE"); err != nil {
+		return 0, err
+	}
+	var found bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM public.event)").Scan(&found); err != nil {
+		return 0, err
+	}
+	if found {
+		return 0, nil
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO public.event SELECT (jsonb_populate_record(NULL::public.event, "+
+			"$1::jsonb || jsonb_build_object('id', nextval('public.hibernate_sequence')))).*", string(data)); err != nil {
+			return 0, newScriptError(err, "")
+		}
+	}
+
+	return len(events), tx.Commit()
+}
+
+// ExportEventsJSON returns the rows of the event table as a JSON array ordered by id, and how many there are
+func (c *Client) ExportEventsJSON(ctx context.Context) (string, int, error) {
+	var document string
+	var count int
+	err := c.db.QueryRowContext(ctx, "SELECT COALESCE(json_agg(e ORDER BY e.id), '[]')::text, count(*) FROM public.event e").Scan(&document, &count)
+	return document, count, err
+}
+
+// ExportEventsCSV returns the rows of the event table as CSV with a header, ordered by id, and how many there are.
+// NULL columns are written as empty fields
+func (c *Client) ExportEventsCSV(ctx context.Context) (string, int, error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT * FROM public.event ORDER BY id")
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+	var document bytes.Buffer
+	writer := csv.NewWriter(&document)
+	if err := writer.Write(columns); err != nil {
+		return "", 0, err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return "", 0, err
+		}
+		for i, value := range values {
+			record[i] = value.String
+		}
+		if err := writer.Write(record); err != nil {
+			return "", 0, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", 0, err
+	}
+	writer.Flush()
+
+	return document.String(), count, writer.Error()
+}
+
+// ImportEvents runs the given import script, in a single transaction, over the events in the given JSON array, which
+// the script finds in the temporary table event_import_data, returns how many events were imported
+func (c *Client) ImportEvents(ctx context.Context, script string, events string) (int, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "CREATE TEMPORARY TABLE event_import_data (event jsonb) ON COMMIT DROP"); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, "INSERT INTO event_import_data SELECT jsonb_array_elements($1::jsonb)", events)
+	if err != nil {
+		return 0, newScriptError(err, "")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return 0, newScriptError(err, script)
+	}
+
+	return int(count), tx.Commit()
+}
+
+// IsInRecovery returns true if the database is a standby replaying the WAL of a primary
+func (c *Client) IsInRecovery(ctx context.Context) (bool, error) {
+	var inRecovery bool
+	err := c.db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	return inRecovery, err
+}
+
+// CurrentWALPosition returns the current write-ahead log position of a primary
+func (c *Client) CurrentWALPosition(ctx context.Context) (string, error) {
+	var position string
+	err := c.db.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&position)
+	return position, err
+}
+
+// ReplicationLag returns how many bytes of WAL behind the given position a standby has replayed, and whether it's
+// still in recovery
+func (c *Client) ReplicationLag(ctx context.Context, position string) (int64, bool, error) {
+	var lag int64
+	var inRecovery bool
+	if err := c.db.QueryRowContext(ctx, "SELECT COALESCE(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)::bigint, pg_is_in_recovery()", position).Scan(&lag, &inRecovery); err != nil {
+		return 0, false, err
+	}
+	if lag < 0 {
+		lag = 0
+	}
+	return lag, inRecovery, nil
+}
@@ -0,0 +1,32 @@
+--
+-- Upserts into public.event the events in event_import_data, a temporary table with a JSON document per event in
+-- the column event, keyed by the columns of public.event. It has to be run in the transaction that filled it.
+--
+-- An event with the same name, artist, location and start date as an existing one updates it, any other event is
+-- inserted with a new id. Ids in the documents are ignored, they belong to the database they were exported from.
+-- Exports taken before events-database-update-0.0.2.sql only carry date, it fills start_date and end_date.
+--
+
+LOCK TABLE public.event IN EXCLUSIVE MODE;
+
+CREATE TEMPORARY TABLE event_import ON COMMIT DROP AS SELECT * FROM public.event WITH NO DATA;
+
+INSERT INTO event_import
+    SELECT (jsonb_populate_record(NULL::event_import, (event - 'id') || jsonb_build_object(
+        'date', COALESCE(event->>'date', event->>'start_date'),
+        'start_date', COALESCE(event->>'start_date', event->>'date'),
+        'end_date', COALESCE(event->>'end_date', event->>'start_date', event->>'date')))).*
+    FROM event_import_data;
+
+UPDATE event_import i SET id = e.id
+    FROM public.event e
+    WHERE e.name IS NOT DISTINCT FROM i.name
+      AND e.artist IS NOT DISTINCT FROM i.artist
+      AND e.location IS NOT DISTINCT FROM i.location
+      AND e.start_date IS NOT DISTINCT FROM i.start_date;
+
+UPDATE event_import SET id = nextval('public.hibernate_sequence') WHERE id IS NULL;
+
+DELETE FROM public.event e USING event_import i WHERE e.id = i.id;
+
+INSERT INTO public.event SELECT * FROM event_import;
@@ -0,0 +1,11 @@
+--
+-- Undoes events-database-update-0.0.2.sql, date is filled from start_date for events created since then
+--
+
+UPDATE public.event SET date = start_date WHERE date IS NULL AND start_date IS NOT NULL;
+
+ALTER TABLE public.event
+    DROP COLUMN IF EXISTS start_date,
+    DROP COLUMN IF EXISTS end_date;
+
+DELETE FROM public.operator_version WHERE version = '0.0.2';
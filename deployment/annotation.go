@@ -11,6 +11,24 @@ const (
 	ref  = "master"
 )
 
+// Operator annotations
+const (
+	// RestoreAnnotation flags a Deployment scaled down by the given AppServiceRestore
+	RestoreAnnotation = "gramola.atarazana.com/restore"
+	// CredentialsRotationAnnotation records in the Events pod template the credentials rotation its pods were rolled out for
+	CredentialsRotationAnnotation = "gramola.atarazana.com/credentials-rotation"
+	// MigrationDriftAcknowledgeAnnotation lists in the AppService the checksums of changed update scripts that are accepted as applied
+	MigrationDriftAcknowledgeAnnotation = "gramola.atarazana.com/acknowledge-migration-drift"
+	// MigrationApprovalAnnotation sets in the AppService the highest version update scripts can be run up to in Manual migration mode
+	MigrationApprovalAnnotation = "gramola.atarazana.com/approve-migration"
+	// DatabaseUpgradeAnnotation flags the Events Deployment scaled down while the Events Database is upgraded to the given version
+	DatabaseUpgradeAnnotation = "gramola.atarazana.com/database-upgrade"
+	// DatabaseUpgradeConfirmAnnotation sets in the AppService the version whose upgrade is confirmed, so that the previous volume can be deleted
+	DatabaseUpgradeConfirmAnnotation = "gramola.atarazana.com/confirm-database-upgrade"
+	// PoolerConfigChecksumAnnotation records in the pooler pod template the checksum of the configuration its pods were rolled out for
+	PoolerConfigChecksumAnnotation = "gramola.atarazana.com/pooler-config-checksum"
+)
+
 // GetEventsAnnotations returns a map with the annotations for Events
 func GetEventsAnnotations(cr *gramolav1.AppService) (labels map[string]string) {
 	annotations := map[string]string{
@@ -0,0 +1,358 @@
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database backups names
+const (
+	EventsDatabaseBackupName                      = EventsDatabaseServiceName + "-backup"
+	EventsDatabaseBackupPersistentVolumeClaimName = EventsDatabaseBackupName
+	EventsDatabaseBackupPersistentVolumeClaimSize = "1Gi"
+	EventsDatabaseBackupVolumeName                = EventsDatabaseBackupName + "-data"
+	EventsDatabaseBackupMountPath                 = "/backup"
+	EventsDatabaseBackupFileSuffix                = ".sql"
+
+	EventsDatabasePreUpdateBackupFilePrefix = EventsDatabaseBackupName + "-pre-"
+
+	EventsDatabaseScheduledBackupName                      = EventsDatabaseBackupName + "-scheduled"
+	EventsDatabaseScheduledBackupPersistentVolumeClaimName = EventsDatabaseScheduledBackupName
+	EventsDatabaseScheduledBackupVolumeName                = EventsDatabaseScheduledBackupName + "-data"
+	EventsDatabaseScheduledBackupPath                      = EventsDatabaseBackupMountPath + "/scheduled"
+	EventsDatabaseScheduledBackupFilePrefix                = EventsDatabaseServiceName + "-"
+
+	eventsDatabaseRestorePathEnvVarName = "RESTORE_PATH"
+
+	eventsDatabasePreUpdateBackupHashLength = 10
+
+	// eventsDatabaseDumpCommand dumps the Events Database so that it can be restored by the user of any AppService
+	eventsDatabaseDumpCommand = "pg_dump --clean --if-exists --no-owner --no-privileges"
+)
+
+// EventsDatabasePreUpdateBackupsKept number of pre-update backup files kept in the backups PVC, the older ones are
+// removed before a new one is taken
+var EventsDatabasePreUpdateBackupsKept = 3
+
+// EventsDatabaseScheduledBackupJobsHistoryLimit number of finished scheduled backup Jobs kept, it doesn't affect the backup files
+var EventsDatabaseScheduledBackupJobsHistoryLimit = int32(3)
+
+// EventsDatabasePreUpdateBackupJobNameFor returns the name of the Job that backs up the database before updating it to the given version,
+// versions too long for the name of a Job are replaced by a short hash of them
+func EventsDatabasePreUpdateBackupJobNameFor(version string) string {
+	name := EventsDatabasePreUpdateBackupFilePrefix + strings.NewReplacer(".", "-", EventsDatabaseCustomScriptVersionSeparator, "-").Replace(version)
+	if len(name) > validation.DNS1123LabelMaxLength {
+		checksum := sha256.Sum256([]byte(version))
+		name = EventsDatabasePreUpdateBackupFilePrefix + hex.EncodeToString(checksum[:])[:eventsDatabasePreUpdateBackupHashLength]
+	}
+	return name
+}
+
+// EventsDatabaseBackupJobNameFor returns the name of the Job that takes the given on-demand backup
+func EventsDatabaseBackupJobNameFor(backupName string) string {
+	return EventsDatabaseBackupName + "-" + backupName
+}
+
+// EventsDatabaseRestoreJobNameFor returns the name of the Job that runs the given restore
+func EventsDatabaseRestoreJobNameFor(restoreName string) string {
+	return EventsDatabaseServiceName + "-restore-" + restoreName
+}
+
+// EventsDatabaseBackupPathFor returns the path of the backup file written by the given backup Job
+func EventsDatabaseBackupPathFor(jobName string) string {
+	return EventsDatabaseBackupMountPath + "/" + jobName + EventsDatabaseBackupFileSuffix
+}
+
+// GetEventsDatabaseBackupFilePath returns the absolute path of the given backup file, relative paths are relative to the
+// backups PVC. Paths that leave the backups PVC are refused
+func GetEventsDatabaseBackupFilePath(filePath string) (string, error) {
+	if !path.IsAbs(filePath) {
+		filePath = EventsDatabaseBackupMountPath + "/" + filePath
+	}
+	filePath = path.Clean(filePath)
+	if !strings.HasPrefix(filePath, EventsDatabaseBackupMountPath+"/") {
+		return "", util.NewError("Path " + filePath + " is not in the backups volume " + EventsDatabaseBackupMountPath)
+	}
+	return filePath, nil
+}
+
+// GetEventsDatabaseBackupStorageSize returns the size of the backups PVC, twice the size of the Events Database volume
+// so that it grows along with the dumps, and never below 1Gi
+func GetEventsDatabaseBackupStorageSize(instance *gramolav1.AppService) resource.Quantity {
+	size := GetEventsDatabaseStorageSize(instance)
+	size.Add(size)
+	if minSize := resource.MustParse(EventsDatabaseBackupPersistentVolumeClaimSize); size.Cmp(minSize) < 0 {
+		return minSize
+	}
+	return size
+}
+
+// NewEventsDatabaseBackupPersistentVolumeClaim returns the PVC where the Events Database backups are stored
+func NewEventsDatabaseBackupPersistentVolumeClaim(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.PersistentVolumeClaim, error) {
+	pvc := NewPersistentVolumeClaim(instance, EventsDatabaseBackupPersistentVolumeClaimName, instance.Namespace, EventsDatabaseBackupPersistentVolumeClaimSize)
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = GetEventsDatabaseBackupStorageSize(instance)
+
+	if err := controllerutil.SetControllerReference(instance, pvc, scheme); err != nil {
+		return nil, err
+	}
+
+	return pvc, nil
+}
+
+// GetEventsDatabaseScheduledBackupStorageSize returns the size of the scheduled backups PVC as set in spec.backup.storage,
+// the size of the backups PVC if not set
+func GetEventsDatabaseScheduledBackupStorageSize(instance *gramolav1.AppService) resource.Quantity {
+	if instance.Spec.Backup != nil && instance.Spec.Backup.Storage != nil && instance.Spec.Backup.Storage.Size != nil {
+		return *instance.Spec.Backup.Storage.Size
+	}
+	return GetEventsDatabaseBackupStorageSize(instance)
+}
+
+// NewEventsDatabaseScheduledBackupPersistentVolumeClaim returns the PVC where the scheduled backups are stored as set in
+// spec.backup.storage
+func NewEventsDatabaseScheduledBackupPersistentVolumeClaim(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.PersistentVolumeClaim, error) {
+	var storage *gramolav1.StorageSpec
+	if instance.Spec.Backup != nil {
+		storage = instance.Spec.Backup.Storage
+	}
+	pvc := newPersistentVolumeClaimFromStorageSpec(instance, EventsDatabaseScheduledBackupPersistentVolumeClaimName, storage, GetEventsDatabaseScheduledBackupStorageSize(instance))
+
+	if err := controllerutil.SetControllerReference(instance, pvc, scheme); err != nil {
+		return nil, err
+	}
+
+	return pvc, nil
+}
+
+// newEventsDatabaseBackupVolumes returns the volume and mount of the backups PVC
+func newEventsDatabaseBackupVolumes() ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{
+		{
+			Name: EventsDatabaseBackupVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: EventsDatabaseBackupPersistentVolumeClaimName,
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      EventsDatabaseBackupVolumeName,
+			MountPath: EventsDatabaseBackupMountPath,
+		},
+	}
+
+	return volumes, volumeMounts
+}
+
+// newEventsDatabaseScheduledBackupVolumes returns the volume and mount of the scheduled backups PVC, it's mounted where
+// the scheduled backups are found in the backups PVC
+func newEventsDatabaseScheduledBackupVolumes() ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{
+		{
+			Name: EventsDatabaseScheduledBackupVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: EventsDatabaseScheduledBackupPersistentVolumeClaimName,
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      EventsDatabaseScheduledBackupVolumeName,
+			MountPath: EventsDatabaseScheduledBackupPath,
+		},
+	}
+
+	return volumes, volumeMounts
+}
+
+// NewEventsDatabaseBackupJob returns a Job, controlled by owner, that dumps the Events Database into the backups PVC, the file
+// is named after the Job and the dump drops objects before creating them so it can be restored over a live database.
+// Owners and privileges are left out, the objects belong to whichever user restores them
+func NewEventsDatabaseBackupJob(instance *gramolav1.AppService, owner metav1.Object, scheme *runtime.Scheme, name string) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseBackupName)
+
+	volumes, volumeMounts := newEventsDatabaseBackupVolumes()
+	command := eventsDatabaseDumpCommand + " -f " + EventsDatabaseBackupPathFor(name)
+
+	job := newEventsDatabaseJob(instance, name, labels, command, volumes, volumeMounts)
+
+	if err := controllerutil.SetControllerReference(owner, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// NewEventsDatabasePreUpdateBackupJob returns a Job that backs up the Events Database before an update as
+// NewEventsDatabaseBackupJob does, it first removes the oldest pre-update backups so that they don't fill the backups PVC
+func NewEventsDatabasePreUpdateBackupJob(instance *gramolav1.AppService, scheme *runtime.Scheme, name string) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseBackupName)
+
+	files := EventsDatabaseBackupMountPath + "/" + EventsDatabasePreUpdateBackupFilePrefix + "*" + EventsDatabaseBackupFileSuffix
+	volumes, volumeMounts := newEventsDatabaseBackupVolumes()
+	command := strings.Join([]string{
+		"set -e",
+		fmt.Sprintf("ls -1t %s | tail -n +%d | xargs -r rm -fv", files, EventsDatabasePreUpdateBackupsKept),
+		eventsDatabaseDumpCommand + " -f " + EventsDatabaseBackupPathFor(name),
+	}, "\n")
+
+	job := newEventsDatabaseJob(instance, name, labels, command, volumes, volumeMounts)
+
+	if err := controllerutil.SetControllerReference(instance, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// NewEventsDatabaseRestoreJob returns a Job, controlled by owner, that loads the given backup file of the backups PVC
+// into the Events Database in a single transaction
+func NewEventsDatabaseRestoreJob(instance *gramolav1.AppService, owner metav1.Object, scheme *runtime.Scheme, name string, filePath string) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName+"-restore")
+
+	filePath, err := GetEventsDatabaseBackupFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The path is passed in the environment so that the shell doesn't interpret it
+	volumes, volumeMounts := newEventsDatabaseBackupVolumes()
+	if strings.HasPrefix(filePath, EventsDatabaseScheduledBackupPath+"/") {
+		scheduledVolumes, scheduledVolumeMounts := newEventsDatabaseScheduledBackupVolumes()
+		volumes = append(volumes, scheduledVolumes...)
+		volumeMounts = append(volumeMounts, scheduledVolumeMounts...)
+	}
+	command := eventsDatabasePsqlCommand + " --single-transaction -f \"${" + eventsDatabaseRestorePathEnvVarName + "}\""
+
+	job := newEventsDatabaseJob(instance, name, labels, command, volumes, volumeMounts)
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  eventsDatabaseRestorePathEnvVarName,
+		Value: filePath,
+	})
+
+	if err := controllerutil.SetControllerReference(owner, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// newEventsDatabaseScheduledBackupCommand returns the script run by the scheduled backups: it dumps the Events Database
+// into a timestamped file under the scheduled folder of the backups PVC and then prunes the files outside retention.
+// The dump is written to a partial file first so that a failed run never counts as a backup
+func newEventsDatabaseScheduledBackupCommand(retention *gramolav1.BackupRetention) string {
+	files := EventsDatabaseScheduledBackupPath + "/" + EventsDatabaseScheduledBackupFilePrefix + "*" + EventsDatabaseBackupFileSuffix
+
+	command := []string{
+		"set -e",
+		"mkdir -p " + EventsDatabaseScheduledBackupPath,
+		"rm -f " + files + ".partial",
+		"FILE=" + EventsDatabaseScheduledBackupPath + "/" + EventsDatabaseScheduledBackupFilePrefix + "$(date -u +%Y%m%d%H%M%S)" + EventsDatabaseBackupFileSuffix,
+		eventsDatabaseDumpCommand + " -f ${FILE}.partial",
+		"mv ${FILE}.partial ${FILE}",
+		"echo \"Backup ${FILE} completed\"",
+	}
+
+	if retention != nil {
+		if retention.Count != nil {
+			command = append(command, fmt.Sprintf("ls -1t %s | tail -n +%d | xargs -r rm -fv", files, *retention.Count+1))
+		}
+		if retention.MaxAge != nil {
+			minutes := int64(math.Max(1, math.Ceil(retention.MaxAge.Minutes())))
+			command = append(command, fmt.Sprintf("find %s -maxdepth 1 -name '%s' -mmin +%d -print -delete",
+				EventsDatabaseScheduledBackupPath, EventsDatabaseScheduledBackupFilePrefix+"*"+EventsDatabaseBackupFileSuffix, minutes))
+		}
+	}
+
+	return strings.Join(command, "\n")
+}
+
+// newEventsDatabaseScheduledBackupJob returns the Job the scheduled backups CronJob runs
+func newEventsDatabaseScheduledBackupJob(instance *gramolav1.AppService, labels map[string]string) *batchv1.Job {
+	volumes, volumeMounts := newEventsDatabaseScheduledBackupVolumes()
+	command := newEventsDatabaseScheduledBackupCommand(instance.Spec.Backup.Retention)
+
+	return newEventsDatabaseJob(instance, EventsDatabaseScheduledBackupName, labels, command, volumes, volumeMounts)
+}
+
+// NewEventsDatabaseScheduledBackupCronJob returns a CronJob that backs up the Events Database following spec.backup
+func NewEventsDatabaseScheduledBackupCronJob(instance *gramolav1.AppService, scheme *runtime.Scheme) (*batchv1beta1.CronJob, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseScheduledBackupName)
+	labels[AppServiceNameLabel] = instance.Name
+
+	job := newEventsDatabaseScheduledBackupJob(instance, labels)
+
+	suspend := instance.Spec.Backup.Suspend
+
+	cronJob := &batchv1beta1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CronJob",
+			APIVersion: "batch/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabaseScheduledBackupName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   instance.Spec.Backup.Schedule,
+			Suspend:                    &suspend,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &EventsDatabaseScheduledBackupJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &EventsDatabaseScheduledBackupJobsHistoryLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: job.Spec,
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, cronJob, scheme); err != nil {
+		return nil, err
+	}
+
+	return cronJob, nil
+}
+
+// NewEventsDatabaseScheduledBackupCronJobPatch returns a Patch
+func NewEventsDatabaseScheduledBackupCronJobPatch(current *batchv1beta1.CronJob, instance *gramolav1.AppService) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	suspend := instance.Spec.Backup.Suspend
+
+	current.Spec.Schedule = instance.Spec.Backup.Schedule
+	current.Spec.Suspend = &suspend
+	// Scheduled backups created before they had their own PVC move to it
+	job := newEventsDatabaseScheduledBackupJob(instance, current.Spec.JobTemplate.Labels)
+	current.Spec.JobTemplate.Spec.Template.Spec.Volumes = job.Spec.Template.Spec.Volumes
+	current.Spec.JobTemplate.Spec.Template.Spec.Containers[0].VolumeMounts = job.Spec.Template.Spec.Containers[0].VolumeMounts
+	current.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image = job.Spec.Template.Spec.Containers[0].Image
+	current.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Command = job.Spec.Template.Spec.Containers[0].Command
+
+	return patch
+}
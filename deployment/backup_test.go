@@ -0,0 +1,189 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+)
+
+func TestNewEventsDatabaseScheduledBackupCommand(t *testing.T) {
+	count := int32(7)
+
+	tests := []struct {
+		name      string
+		retention *gramolav1.BackupRetention
+		want      []string
+	}{
+		{
+			name: "no retention",
+		},
+		{
+			name:      "empty retention",
+			retention: &gramolav1.BackupRetention{},
+		},
+		{
+			name:      "count",
+			retention: &gramolav1.BackupRetention{Count: &count},
+			want: []string{
+				"ls -1t /backup/scheduled/events-database-*.sql | tail -n +8 | xargs -r rm -fv",
+			},
+		},
+		{
+			name:      "max age",
+			retention: &gramolav1.BackupRetention{MaxAge: &metav1.Duration{Duration: 168 * time.Hour}},
+			want: []string{
+				"find /backup/scheduled -maxdepth 1 -name 'events-database-*.sql' -mmin +10080 -print -delete",
+			},
+		},
+		{
+			name:      "max age rounded up to minutes",
+			retention: &gramolav1.BackupRetention{MaxAge: &metav1.Duration{Duration: 90 * time.Second}},
+			want: []string{
+				"find /backup/scheduled -maxdepth 1 -name 'events-database-*.sql' -mmin +2 -print -delete",
+			},
+		},
+		{
+			name:      "max age below a minute",
+			retention: &gramolav1.BackupRetention{MaxAge: &metav1.Duration{Duration: 10 * time.Second}},
+			want: []string{
+				"find /backup/scheduled -maxdepth 1 -name 'events-database-*.sql' -mmin +1 -print -delete",
+			},
+		},
+		{
+			name:      "count and max age",
+			retention: &gramolav1.BackupRetention{Count: &count, MaxAge: &metav1.Duration{Duration: 24 * time.Hour}},
+			want: []string{
+				"ls -1t /backup/scheduled/events-database-*.sql | tail -n +8 | xargs -r rm -fv",
+				"find /backup/scheduled -maxdepth 1 -name 'events-database-*.sql' -mmin +1440 -print -delete",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := strings.Split(newEventsDatabaseScheduledBackupCommand(test.retention), "\n")
+
+			// The backup always runs, and only once it's complete the old ones are pruned
+			backup := []string{
+				"set -e",
+				"mkdir -p /backup/scheduled",
+				"rm -f /backup/scheduled/events-database-*.sql.partial",
+				"FILE=/backup/scheduled/events-database-$(date -u +%Y%m%d%H%M%S).sql",
+				"pg_dump --clean --if-exists --no-owner --no-privileges -f ${FILE}.partial",
+				"mv ${FILE}.partial ${FILE}",
+				"echo \"Backup ${FILE} completed\"",
+			}
+			if len(lines) != len(backup)+len(test.want) {
+				t.Fatalf("newEventsDatabaseScheduledBackupCommand() = %q, want %d lines", lines, len(backup)+len(test.want))
+			}
+			for i, want := range append(backup, test.want...) {
+				if lines[i] != want {
+					t.Errorf("newEventsDatabaseScheduledBackupCommand() line %d = %q, want %q", i+1, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEventsDatabaseBackupFilePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "events-database-backup-nightly.sql", want: "/backup/events-database-backup-nightly.sql"},
+		{path: "scheduled/events-database-20210301000000.sql", want: "/backup/scheduled/events-database-20210301000000.sql"},
+		{path: "/backup/my backup.sql", want: "/backup/my backup.sql"},
+		{path: "scheduled/../nightly.sql", want: "/backup/nightly.sql"},
+		{path: "x.sql; psql -c 'DROP TABLE event'", want: "/backup/x.sql; psql -c 'DROP TABLE event'"},
+		{path: "../etc/passwd", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+		{path: "/backup", wantErr: true},
+		{path: "/backups/nightly.sql", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := GetEventsDatabaseBackupFilePath(test.path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetEventsDatabaseBackupFilePath() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("GetEventsDatabaseBackupFilePath() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetEventsDatabaseBackupStorageSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size string
+		want string
+	}{
+		{name: "default storage", want: "1Gi"},
+		{name: "small storage", size: "256Mi", want: "1Gi"},
+		{name: "large storage", size: "20Gi", want: "40Gi"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := &gramolav1.AppService{}
+			if len(test.size) > 0 {
+				size := resource.MustParse(test.size)
+				instance.Spec.Database = &gramolav1.DatabaseSpec{Storage: &gramolav1.StorageSpec{Size: &size}}
+			}
+			want := resource.MustParse(test.want)
+			if got := GetEventsDatabaseBackupStorageSize(instance); got.Cmp(want) != 0 {
+				t.Errorf("GetEventsDatabaseBackupStorageSize() = %s, want %s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestGetEventsDatabaseScheduledBackupStorageSize(t *testing.T) {
+	databaseSize := resource.MustParse("2Gi")
+	scheduledSize := resource.MustParse("10Gi")
+
+	tests := []struct {
+		name   string
+		backup *gramolav1.BackupSpec
+		want   string
+	}{
+		{name: "no storage", backup: &gramolav1.BackupSpec{Schedule: "0 2 * * *"}, want: "4Gi"},
+		{name: "storage without size", backup: &gramolav1.BackupSpec{Schedule: "0 2 * * *", Storage: &gramolav1.StorageSpec{}}, want: "4Gi"},
+		{name: "storage size", backup: &gramolav1.BackupSpec{Schedule: "0 2 * * *", Storage: &gramolav1.StorageSpec{Size: &scheduledSize}}, want: "10Gi"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := &gramolav1.AppService{}
+			instance.Spec.Database = &gramolav1.DatabaseSpec{Storage: &gramolav1.StorageSpec{Size: &databaseSize}}
+			instance.Spec.Backup = test.backup
+			want := resource.MustParse(test.want)
+			if got := GetEventsDatabaseScheduledBackupStorageSize(instance); got.Cmp(want) != 0 {
+				t.Errorf("GetEventsDatabaseScheduledBackupStorageSize() = %s, want %s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestEventsDatabasePreUpdateBackupJobNameFor(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "0.0.3", want: "events-database-backup-pre-0-0-3"},
+		{version: "reporting:1.10.0", want: "events-database-backup-pre-reporting-1-10-0"},
+		{version: "rollback-reporting:1.10.0", want: "events-database-backup-pre-rollback-reporting-1-10-0"},
+		{version: "reporting-views-and-indexes-for-events:1.10.0", want: "events-database-backup-pre-69fc3c2c8c"},
+	}
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			if got := EventsDatabasePreUpdateBackupJobNameFor(test.version); got != test.want {
+				t.Errorf("EventsDatabasePreUpdateBackupJobNameFor() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
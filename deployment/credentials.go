@@ -0,0 +1,107 @@
+package deployment
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database credentials rotation names
+const (
+	// EventsDatabaseNextPasswordKey holds the password being rotated in until the database accepts it
+	EventsDatabaseNextPasswordKey = EventsDatabasePasswordKey + "-next"
+
+	EventsDatabaseCredentialsRotationJobName = EventsDatabaseServiceName + "-rotate-credentials"
+)
+
+// EventsDatabaseCredentialsRotationJobNameFor returns the name of the Job that runs the given rotation
+func EventsDatabaseCredentialsRotationJobNameFor(rotation int64) string {
+	return EventsDatabaseCredentialsRotationJobName + "-" + strconv.FormatInt(rotation, 10)
+}
+
+// NewEventsDatabaseCredentialsRotationJob returns a Job that changes the password of the Events Database user to the
+// one stored in the next password key of the credentials Secret
+func NewEventsDatabaseCredentialsRotationJob(instance *gramolav1.AppService, scheme *runtime.Scheme, rotation int64) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseCredentialsRotationJobName)
+
+	// The password is passed as a psql variable so that it's quoted properly
+	command := "echo \"ALTER USER CURRENT_USER WITH PASSWORD :'new_password';\" | " +
+		eventsDatabasePsqlCommand + " -v new_password=\"${NEW_PGPASSWORD}\""
+
+	job := newEventsDatabaseJob(instance, EventsDatabaseCredentialsRotationJobNameFor(rotation), labels, command, nil, nil)
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name: "NEW_PGPASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key: EventsDatabaseNextPasswordKey,
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: GetEventsDatabaseCredentialsSecretName(instance),
+				},
+			},
+		},
+	})
+
+	if err := controllerutil.SetControllerReference(instance, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// NewEventsDatabaseNextPasswordPatch returns a Patch that stores a newly generated password in the next password key
+func NewEventsDatabaseNextPasswordPatch(current *corev1.Secret) (client.Patch, error) {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	password, err := util.RandomString(EventsDatabaseGeneratedPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Data == nil {
+		current.Data = map[string][]byte{}
+	}
+	current.Data[EventsDatabaseNextPasswordKey] = []byte(password)
+
+	return patch, nil
+}
+
+// NewEventsDatabaseSwapPasswordPatch returns a Patch that replaces the password with the next one once the database accepted it
+func NewEventsDatabaseSwapPasswordPatch(current *corev1.Secret) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Data[EventsDatabasePasswordKey] = current.Data[EventsDatabaseNextPasswordKey]
+	delete(current.Data, EventsDatabaseNextPasswordKey)
+
+	return patch
+}
+
+// NewEventsDatabaseDiscardPasswordPatch returns a Patch that drops the next password when the database didn't accept it
+func NewEventsDatabaseDiscardPasswordPatch(current *corev1.Secret) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	delete(current.Data, EventsDatabaseNextPasswordKey)
+
+	return patch
+}
+
+// NewEventsDeploymentRolloutPatch returns a Patch that rolls out the Events pods so that they read the rotated credentials
+func NewEventsDeploymentRolloutPatch(current *appsv1.Deployment, rotation int64) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	if current.Spec.Template.Annotations == nil {
+		current.Spec.Template.Annotations = map[string]string{}
+	}
+	current.Spec.Template.Annotations[CredentialsRotationAnnotation] = strconv.FormatInt(rotation, 10)
+
+	return patch
+}
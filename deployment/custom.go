@@ -0,0 +1,86 @@
+package deployment
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	semver "github.com/blang/semver"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+)
+
+// EventsDatabaseCustomScriptVersionSeparator splits the ConfigMap and the version custom scripts are tracked with,
+// i.e. reporting:1.0.0
+const EventsDatabaseCustomScriptVersionSeparator = ":"
+
+// EventsDatabaseTrackedVersionMaxLength is the length of the versions operator_version can record
+const EventsDatabaseTrackedVersionMaxLength = 50
+
+// eventsDatabaseCustomScriptKeyRegex matches the keys of custom update scripts, <name>-<version>.sql or <version>.sql
+var eventsDatabaseCustomScriptKeyRegex = regexp.MustCompile(`^(?:.*-)?(\d+\.\d+\.\d+)\.sql$`)
+
+// GetEventsDatabaseCustomScriptsConfigMaps returns the names of the ConfigMaps with custom update scripts in the order
+// they're run
+func GetEventsDatabaseCustomScriptsConfigMaps(instance *gramolav1.AppService) []string {
+	names := []string{}
+	if instance.Spec.Database == nil {
+		return names
+	}
+	for _, customScripts := range instance.Spec.Database.CustomScripts {
+		names = append(names, customScripts.Name)
+	}
+	return names
+}
+
+// GetEventsDatabaseCustomScripts returns, ordered by version, the update scripts in the given ConfigMap. Keys ending
+// in -down.sql are the down scripts of the key with the same name ending in .sql
+func GetEventsDatabaseCustomScripts(configMap *corev1.ConfigMap) ([]EventsDatabaseUpdateScript, error) {
+	scripts := []EventsDatabaseUpdateScript{}
+	versions := map[string]string{}
+	for key, data := range configMap.Data {
+		if strings.HasSuffix(key, EventsDatabaseDownScriptSuffix) {
+			continue
+		}
+		match := eventsDatabaseCustomScriptKeyRegex.FindStringSubmatch(key)
+		if match == nil {
+			return nil, util.NewError("Key " + key + " of ConfigMap " + configMap.Name + " is not named <name>-<version>.sql")
+		}
+		version, err := semver.Parse(match[1])
+		if err != nil {
+			return nil, util.NewError("Key " + key + " of ConfigMap " + configMap.Name + " has not a valid version: " + err.Error())
+		}
+		if other, found := versions[version.String()]; found {
+			return nil, util.NewError("Keys " + other + " and " + key + " of ConfigMap " + configMap.Name + " have the same version")
+		}
+		versions[version.String()] = key
+
+		// Names are valid ConfigMap keys so that the scripts can be mounted along with the built-in ones
+		updateScript := EventsDatabaseUpdateScript{
+			Name:      configMap.Name + "." + key,
+			Version:   version,
+			ConfigMap: configMap.Name,
+			Data:      data,
+		}
+		if len(updateScript.TrackedVersion()) > EventsDatabaseTrackedVersionMaxLength {
+			return nil, util.NewError("Key " + key + " of ConfigMap " + configMap.Name + " would be tracked as " + updateScript.TrackedVersion() +
+				", longer than " + strconv.Itoa(EventsDatabaseTrackedVersionMaxLength) + " characters, the ConfigMap name has to be shorter")
+		}
+		downKey := strings.TrimSuffix(key, EventsDatabaseUpdateScriptSuffix) + EventsDatabaseDownScriptSuffix
+		if downData, found := configMap.Data[downKey]; found {
+			updateScript.DownName = configMap.Name + "." + downKey
+			updateScript.DownData = downData
+		}
+		scripts = append(scripts, updateScript)
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Version.LT(scripts[j].Version)
+	})
+
+	return scripts, nil
+}
@@ -0,0 +1,98 @@
+package deployment
+
+import (
+	"reflect"
+	"testing"
+
+	semver "github.com/blang/semver"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetEventsDatabaseCustomScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    []EventsDatabaseUpdateScript
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			data: map[string]string{
+				"views-1.10.0.sql": "CREATE VIEW b AS SELECT 1;",
+				"views-1.2.0.sql":  "CREATE VIEW a AS SELECT 1;",
+				"1.9.0.sql":        "CREATE INDEX ON event (date);",
+			},
+			want: []EventsDatabaseUpdateScript{
+				{Name: "reporting.views-1.2.0.sql", Version: semver.MustParse("1.2.0"), ConfigMap: "reporting", Data: "CREATE VIEW a AS SELECT 1;"},
+				{Name: "reporting.1.9.0.sql", Version: semver.MustParse("1.9.0"), ConfigMap: "reporting", Data: "CREATE INDEX ON event (date);"},
+				{Name: "reporting.views-1.10.0.sql", Version: semver.MustParse("1.10.0"), ConfigMap: "reporting", Data: "CREATE VIEW b AS SELECT 1;"},
+			},
+		},
+		{
+			name: "down script",
+			data: map[string]string{
+				"views-1.0.0.sql":      "CREATE VIEW a AS SELECT 1;",
+				"views-1.0.0-down.sql": "DROP VIEW a;",
+			},
+			want: []EventsDatabaseUpdateScript{
+				{Name: "reporting.views-1.0.0.sql", DownName: "reporting.views-1.0.0-down.sql", Version: semver.MustParse("1.0.0"),
+					ConfigMap: "reporting", Data: "CREATE VIEW a AS SELECT 1;", DownData: "DROP VIEW a;"},
+			},
+		},
+		{
+			name: "down script alone",
+			data: map[string]string{"views-1.0.0-down.sql": "DROP VIEW a;"},
+			want: []EventsDatabaseUpdateScript{},
+		},
+		{
+			name:    "key without version",
+			data:    map[string]string{"views.sql": "CREATE VIEW a AS SELECT 1;"},
+			wantErr: true,
+		},
+		{
+			name:    "key with partial version",
+			data:    map[string]string{"views-1.0.sql": "CREATE VIEW a AS SELECT 1;"},
+			wantErr: true,
+		},
+		{
+			name:    "key not a script",
+			data:    map[string]string{"README.md": "Reporting views"},
+			wantErr: true,
+		},
+		{
+			name: "same version twice",
+			data: map[string]string{
+				"views-1.0.0.sql":   "CREATE VIEW a AS SELECT 1;",
+				"indexes-1.0.0.sql": "CREATE INDEX ON event (date);",
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "reporting"},
+				Data:       test.data,
+			}
+			got, err := GetEventsDatabaseCustomScripts(configMap)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetEventsDatabaseCustomScripts() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GetEventsDatabaseCustomScripts() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetEventsDatabaseCustomScriptsLongConfigMapName(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "reporting-views-and-indexes-for-the-events-dashboard"},
+		Data:       map[string]string{"views-1.0.0.sql": "CREATE VIEW a AS SELECT 1;"},
+	}
+	if _, err := GetEventsDatabaseCustomScripts(configMap); err == nil {
+		t.Errorf("GetEventsDatabaseCustomScripts() error = nil, want an error for a version longer than %d characters", EventsDatabaseTrackedVersionMaxLength)
+	}
+}
@@ -0,0 +1,237 @@
+package deployment
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events data exports and imports names
+const (
+	EventsDataExportName             = EventsDatabaseServiceName + "-export"
+	EventsDataImportName             = EventsDatabaseServiceName + "-import"
+	EventsDataVolumeName             = "events-data"
+	EventsDataMountPath              = "/data"
+	EventsDataConfigMapKeyPrefix     = "events"
+	EventsDataImportScriptName       = "events-data-import.sql"
+	EventsDataImportScriptEnvVarName = "EVENTS_DATA_IMPORT_SCRIPT"
+	EventsDataPathEnvVarName         = "EVENTS_DATA_PATH"
+	EventsDataConfigMapMaxSize       = 1024 * 1024
+)
+
+// Queries run by the export Jobs, they return the same rows as the exports to a ConfigMap
+const (
+	eventsDataExportJSONQuery = "SELECT COALESCE(json_agg(e ORDER BY e.id), '[]') FROM public.event e"
+	eventsDataExportCSVQuery  = "SELECT * FROM public.event ORDER BY id"
+)
+
+// eventsDataImportCSVColumnsCheck fails the import if the header of a CSV file has columns the events table doesn't
+const eventsDataImportCSVColumnsCheck = `DO $$
+DECLARE
+    unknown_columns TEXT;
+BEGIN
+    SELECT string_agg(c.attname, ', ') INTO unknown_columns FROM pg_attribute c
+        WHERE c.attrelid = 'event_import_csv'::regclass AND c.attnum > 0 AND NOT c.attisdropped
+        AND c.attname NOT IN (SELECT e.attname FROM pg_attribute e WHERE e.attrelid = 'public.event'::regclass AND e.attnum > 0 AND NOT e.attisdropped);
+    IF unknown_columns IS NOT NULL THEN
+        RAISE EXCEPTION 'Columns not in the events table: %', unknown_columns;
+    END IF;
+END $$;`
+
+// GetEventsDataFormat returns the given format or JSON if it's not set
+func GetEventsDataFormat(format gramolav1.DataFormat) gramolav1.DataFormat {
+	if len(format) <= 0 {
+		return gramolav1.DataFormatJSON
+	}
+	return format
+}
+
+// GetEventsDataFileExtension returns the extension of the files with events in the given format
+func GetEventsDataFileExtension(format gramolav1.DataFormat) string {
+	return "." + strings.ToLower(string(GetEventsDataFormat(format)))
+}
+
+// GetEventsDataConfigMapKey returns the key of the ConfigMap holding events in the given format
+func GetEventsDataConfigMapKey(format gramolav1.DataFormat) string {
+	return EventsDataConfigMapKeyPrefix + GetEventsDataFileExtension(format)
+}
+
+// GetEventsDataFilePath returns the path, as mounted in the export and import Jobs, of the given file of a PVC. It
+// fails if the file is outside the PVC
+func GetEventsDataFilePath(filePath string) (string, error) {
+	filePath = path.Join(EventsDataMountPath, filePath)
+	if err := checkEventsDataFilePath(filePath); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// checkEventsDataFilePath fails if the given path, as mounted in the export and import Jobs, is outside the PVC
+func checkEventsDataFilePath(filePath string) error {
+	if filePath != path.Clean(filePath) || !strings.HasPrefix(filePath, EventsDataMountPath+"/") {
+		return util.NewError("Path " + filePath + " is not in the data volume " + EventsDataMountPath)
+	}
+	return nil
+}
+
+// EventsDataExportJobNameFor returns the name of the Job that runs the given export
+func EventsDataExportJobNameFor(exportName string) string {
+	return EventsDataExportName + "-" + exportName
+}
+
+// EventsDataImportJobNameFor returns the name of the Job that runs the given import
+func EventsDataImportJobNameFor(importName string) string {
+	return EventsDataImportName + "-" + importName
+}
+
+// GetEventsDataImportScript returns the script that upserts the events of an import
+func GetEventsDataImportScript() (string, error) {
+	return util.ReadFile(DbScriptsBasePath, EventsDataImportScriptName)
+}
+
+// ConvertEventsCSVToJSON returns the events of a CSV document with a header, as exported, as a JSON array of
+// documents keyed by the header. Empty fields are left out
+func ConvertEventsCSVToJSON(document string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(document))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", err
+	}
+
+	events := []map[string]string{}
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		event := map[string]string{}
+		for j, value := range record {
+			if len(value) > 0 {
+				event[strings.TrimSpace(records[0][j])] = value
+			}
+		}
+		events = append(events, event)
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// newEventsDataVolumes returns the volume and mount of the PVC events are exported to or imported from
+func newEventsDataVolumes(claimName string) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{
+		{
+			Name: EventsDataVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      EventsDataVolumeName,
+			MountPath: EventsDataMountPath,
+		},
+	}
+
+	return volumes, volumeMounts
+}
+
+// NewEventsDataExportJob returns a Job, controlled by owner, that writes the rows of the events table in the given
+// format to the given file of a PVC
+func NewEventsDataExportJob(instance *gramolav1.AppService, owner metav1.Object, scheme *runtime.Scheme, name string, format gramolav1.DataFormat, claimName string, filePath string) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDataExportName)
+
+	if err := checkEventsDataFilePath(filePath); err != nil {
+		return nil, err
+	}
+
+	// The path is passed in the environment so that neither the shell nor psql interpret it
+	volumes, volumeMounts := newEventsDataVolumes(claimName)
+	command := `mkdir -p "$(dirname "${` + EventsDataPathEnvVarName + `}")" && ` + eventsDatabasePsqlCommand
+	if GetEventsDataFormat(format) == gramolav1.DataFormatCSV {
+		command += ` -c "\copy (` + eventsDataExportCSVQuery + `) TO STDOUT WITH CSV HEADER" > "${` + EventsDataPathEnvVarName + `}"`
+	} else {
+		command += ` -At -o "${` + EventsDataPathEnvVarName + `}" -c "` + eventsDataExportJSONQuery + `"`
+	}
+
+	job := newEventsDatabaseJob(instance, name, labels, command, volumes, volumeMounts)
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  EventsDataPathEnvVarName,
+		Value: filePath,
+	})
+
+	if err := controllerutil.SetControllerReference(owner, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// NewEventsDataImportJob returns a Job, controlled by owner, that upserts the events in the given file of a PVC in a
+// single transaction. The file is loaded into event_import_data, CSV files through a table with a text column per
+// field of the header, and the import script is run over it
+func NewEventsDataImportJob(instance *gramolav1.AppService, owner metav1.Object, scheme *runtime.Scheme, name string, format gramolav1.DataFormat, claimName string, filePath string) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDataImportName)
+
+	importScript, err := GetEventsDataImportScript()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkEventsDataFilePath(filePath); err != nil {
+		return nil, err
+	}
+
+	// The path is passed in the environment so that neither the shell nor psql interpret it, and the columns of the
+	// CSV header are quoted and then checked against the events table
+	file := `"${` + EventsDataPathEnvVarName + `}"`
+	script := []string{"BEGIN;", "CREATE TEMPORARY TABLE event_import_data (event jsonb) ON COMMIT DROP;"}
+	if GetEventsDataFormat(format) == gramolav1.DataFormatCSV {
+		script = append(script,
+			`\set columns `+"`"+`head -n 1 `+file+` | tr -d '\r" ' | tr '[:upper:]' '[:lower:]' | sed 's/[^,]*/"&" text/g'`+"`",
+			"CREATE TEMPORARY TABLE event_import_csv (:columns) ON COMMIT DROP;",
+			eventsDataImportCSVColumnsCheck,
+			`\copy event_import_csv FROM PROGRAM 'cat `+file+`' WITH CSV HEADER`,
+			"INSERT INTO event_import_data SELECT to_jsonb(c) FROM event_import_csv c;")
+	} else {
+		script = append(script,
+			`\set events `+"`"+`cat `+file+"`",
+			"INSERT INTO event_import_data SELECT jsonb_array_elements(:'events'::jsonb);")
+	}
+	script = append(script, importScript, "COMMIT;")
+
+	volumes, volumeMounts := newEventsDataVolumes(claimName)
+	command := `printf '%s\n' "$` + EventsDataImportScriptEnvVarName + `" | ` + eventsDatabasePsqlCommand
+
+	job := newEventsDatabaseJob(instance, name, labels, command, volumes, volumeMounts)
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+		Name:  EventsDataImportScriptEnvVarName,
+		Value: strings.Join(script, "\n"),
+	}, corev1.EnvVar{
+		Name:  EventsDataPathEnvVarName,
+		Value: filePath,
+	})
+
+	if err := controllerutil.SetControllerReference(owner, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
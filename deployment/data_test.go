@@ -0,0 +1,33 @@
+package deployment
+
+import (
+	"testing"
+)
+
+func TestGetEventsDataFilePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "events.json", want: "/data/events.json"},
+		{path: "/exports/events.csv", want: "/data/exports/events.csv"},
+		{path: "exports/../my events.csv", want: "/data/my events.csv"},
+		{path: "x.csv' WITH CSV; $(rm -rf /data)", want: "/data/x.csv' WITH CSV; $(rm -rf /data)"},
+		{path: "../etc/passwd", wantErr: true},
+		{path: "/../../etc/passwd", wantErr: true},
+		{path: "", wantErr: true},
+		{path: ".", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := GetEventsDataFilePath(test.path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetEventsDataFilePath() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("GetEventsDataFilePath() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,18 @@
 package deployment
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+
+	semver "github.com/blang/semver"
 
 	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
 	version "github.com/atarazana/gramola-operator/version"
@@ -35,15 +44,20 @@ const (
 	EventsDatabaseServicePort          = 5432
 	EventsDatabaseServicePortName      = "postgresql"
 	EventsDatabaseServiceImage         = "registry.access.redhat.com/rhscl/postgresql-10-rhel7:latest"
+	EventsDatabaseHostEnvVarName       = "DB_SERVICE_NAME"
 
 	EventsDatabasePersistanceVolumeName      = EventsDatabaseServiceName + "-data"
 	EventsDatabasePersistanceVolumeClaimName = EventsDatabaseServiceName
+	EventsDatabasePersistanceVolumeClaimSize = "512Mi"
+	EventsDatabaseDataMountPath              = "/var/lib/pgsql/data"
 )
 
 // Constants to locate the scripts to update the database
 const (
 	EventsDatabaseScriptsBaseEnvVarName = "DB_SCRIPTS_BASE_DIR"
-	EventsDatabaseUpdateScriptName      = "events-database-update-0.0.2.sql"
+	EventsDatabaseUpdateScriptPrefix    = "events-database-update-"
+	EventsDatabaseUpdateScriptSuffix    = ".sql"
+	EventsDatabaseDownScriptSuffix      = "-down" + EventsDatabaseUpdateScriptSuffix
 	EventsDatabaseScriptsMountPath      = "/operator/scripts"
 
 	EventsDatabaseCredentialsSecretName = EventsDatabaseServiceName
@@ -56,33 +70,365 @@ var EventsDatabaseServiceReplicas = int32(1)
 // EventsServiceReplicas number of replicas for Events Service
 var EventsServiceReplicas = int32(2)
 
-// DatabaseCredentials contains the Database Credentials as a KV map
-var DatabaseCredentials = map[string]string{
-	"database-name":     "eventsdb",
-	"database-password": "secret",
-	"database-user":     "luke",
+// Keys of the Events Database credentials Secret and values used to generate them
+const (
+	EventsDatabaseUserKey     = "database-user"
+	EventsDatabasePasswordKey = "database-password"
+	EventsDatabaseNameKey     = "database-name"
+
+	EventsDatabaseDefaultName             = "eventsdb"
+	EventsDatabaseGeneratedUserPrefix     = "user"
+	EventsDatabaseGeneratedUserLength     = 4
+	EventsDatabaseGeneratedPasswordLength = 16
+)
+
+// Constants to check the certificate of an external Events Database
+const (
+	EventsDatabaseCACertKey        = "ca.crt"
+	EventsDatabaseCACertVolumeName = EventsDatabaseServiceName + "-ca"
+	EventsDatabaseCACertMountPath  = "/operator/ssl"
+)
+
+// GetEventsDatabaseCredentialsSecretName returns the name of the Secret with the Events Database credentials, the one
+// referred by the external database or spec.database.credentialsSecretRef if set or the one generated by the operator otherwise
+func GetEventsDatabaseCredentialsSecretName(instance *gramolav1.AppService) string {
+	if IsEventsDatabaseExternal(instance) {
+		return instance.Spec.Database.External.CredentialsSecretRef.Name
+	}
+	if instance.Spec.Database != nil && instance.Spec.Database.CredentialsSecretRef != nil {
+		return instance.Spec.Database.CredentialsSecretRef.Name
+	}
+	return EventsDatabaseCredentialsSecretName
+}
+
+// AreEventsDatabaseCredentialsGenerated returns true if the operator has to generate the Events Database credentials
+func AreEventsDatabaseCredentialsGenerated(instance *gramolav1.AppService) bool {
+	return instance.Spec.Database == nil || (instance.Spec.Database.CredentialsSecretRef == nil && instance.Spec.Database.External == nil)
+}
+
+// IsEventsDatabaseExternal returns true if the Events Database runs in a server not deployed by the operator
+func IsEventsDatabaseExternal(instance *gramolav1.AppService) bool {
+	return instance.Spec.Database != nil && instance.Spec.Database.External != nil
+}
+
+// GetEventsDatabaseHost returns the host where the Events Database listens
+func GetEventsDatabaseHost(instance *gramolav1.AppService) string {
+	if IsEventsDatabaseExternal(instance) {
+		return instance.Spec.Database.External.Host
+	}
+	return EventsDatabaseServiceName
+}
+
+// GetEventsDatabasePort returns the port where the Events Database listens
+func GetEventsDatabasePort(instance *gramolav1.AppService) int {
+	if IsEventsDatabaseExternal(instance) && instance.Spec.Database.External.Port > 0 {
+		return int(instance.Spec.Database.External.Port)
+	}
+	return EventsDatabaseServicePort
+}
+
+// GetEventsDatabaseSSLMode returns how connections to the Events Database are secured, TLS is off for the database
+// deployed by the operator and required by default for an external one
+func GetEventsDatabaseSSLMode(instance *gramolav1.AppService) gramolav1.DatabaseSSLMode {
+	if !IsEventsDatabaseExternal(instance) {
+		return gramolav1.DatabaseSSLModeDisable
+	}
+	if len(instance.Spec.Database.External.SSLMode) > 0 {
+		return instance.Spec.Database.External.SSLMode
+	}
+	return gramolav1.DatabaseSSLModeRequire
+}
+
+// GetEventsDatabaseCASecretName returns the name of the Secret with the certificate authority of an external Events
+// Database, empty if there's none
+func GetEventsDatabaseCASecretName(instance *gramolav1.AppService) string {
+	if IsEventsDatabaseExternal(instance) && instance.Spec.Database.External.CASecretRef != nil {
+		return instance.Spec.Database.External.CASecretRef.Name
+	}
+	return ""
+}
+
+// newEventsDatabaseNameEnvVar returns an environment variable with the name of the Events Database, external databases
+// set it in the spec while the ones deployed by the operator keep it in the credentials Secret
+func newEventsDatabaseNameEnvVar(instance *gramolav1.AppService, name string) corev1.EnvVar {
+	if IsEventsDatabaseExternal(instance) {
+		return corev1.EnvVar{
+			Name:  name,
+			Value: instance.Spec.Database.External.DatabaseName,
+		}
+	}
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key: EventsDatabaseNameKey,
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: GetEventsDatabaseCredentialsSecretName(instance),
+				},
+			},
+		},
+	}
+}
+
+// newEventsDatabaseCredentials returns a random user and password for the Events Database
+func newEventsDatabaseCredentials() (map[string]string, error) {
+	userSuffix, err := util.RandomString(EventsDatabaseGeneratedUserLength)
+	if err != nil {
+		return nil, err
+	}
+	password, err := util.RandomString(EventsDatabaseGeneratedPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		EventsDatabaseNameKey:     EventsDatabaseDefaultName,
+		EventsDatabasePasswordKey: password,
+		// PostgreSQL folds unquoted identifiers to lower case
+		EventsDatabaseUserKey: EventsDatabaseGeneratedUserPrefix + strings.ToLower(userSuffix),
+	}, nil
 }
 
 // DbScriptsBasePath point to the directory where the scripts to update the database should be
 var DbScriptsBasePath = os.Getenv(EventsDatabaseScriptsBaseEnvVarName) + "/db"
 
-// getDatabaseScriptsMap returns a KV map with script names as Ks and Script File names as Vs
-func getDatabaseScriptsMap() map[string]string {
+// EventsDatabaseUpdateScript is an update script for the Events Database and the version it brings the schema to,
+// DownName is the script that undoes it, if any
+type EventsDatabaseUpdateScript struct {
+	Name     string
+	DownName string
+	Version  semver.Version
+
+	// ConfigMap the script comes from, empty for the scripts built into the operator
+	ConfigMap string
+
+	// Data and DownData of the scripts that come from a ConfigMap
+	Data     string
+	DownData string
+}
+
+// TrackedVersion returns the version the script is recorded with in operator_version, custom scripts are prefixed
+// with their ConfigMap so that they don't clash with the built-in ones
+func (s EventsDatabaseUpdateScript) TrackedVersion() string {
+	if len(s.ConfigMap) > 0 {
+		return s.ConfigMap + EventsDatabaseCustomScriptVersionSeparator + s.Version.String()
+	}
+	return s.Version.String()
+}
+
+// GetEventsDatabaseUpdateScripts returns all the update scripts found in DbScriptsBasePath sorted by version, along
+// with their paired down scripts
+func GetEventsDatabaseUpdateScripts() ([]EventsDatabaseUpdateScript, error) {
+	filePaths, err := filepath.Glob(filepath.Join(DbScriptsBasePath, EventsDatabaseUpdateScriptPrefix+"*"+EventsDatabaseUpdateScriptSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := []EventsDatabaseUpdateScript{}
+	for _, filePath := range filePaths {
+		name := filepath.Base(filePath)
+		if strings.HasSuffix(name, EventsDatabaseDownScriptSuffix) {
+			continue
+		}
+		version, err := semver.Parse(strings.TrimSuffix(strings.TrimPrefix(name, EventsDatabaseUpdateScriptPrefix), EventsDatabaseUpdateScriptSuffix))
+		if err != nil {
+			return nil, util.NewError("Update script " + name + " has not a valid version: " + err.Error())
+		}
+		updateScript := EventsDatabaseUpdateScript{Name: name, Version: version}
+		downName := strings.TrimSuffix(name, EventsDatabaseUpdateScriptSuffix) + EventsDatabaseDownScriptSuffix
+		if _, err := os.Stat(filepath.Join(DbScriptsBasePath, downName)); err == nil {
+			updateScript.DownName = downName
+		}
+		scripts = append(scripts, updateScript)
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Version.LT(scripts[j].Version)
+	})
+
+	return scripts, nil
+}
+
+// EventsDatabaseScriptContext holds what update scripts are rendered with as Go templates, i.e. {{.DatabaseUser}} or
+// {{.Variables.retentionDays}}. Rendering fails if a script refers to anything not set here
+type EventsDatabaseScriptContext struct {
+	DatabaseName    string
+	DatabaseUser    string
+	OperatorVersion string
+	AppServiceName  string
+	Namespace       string
+	Location        string
+	Alias           string
+
+	// Variables set in spec.database.scriptVariables
+	Variables map[string]string
+}
+
+// NewEventsDatabaseScriptContext returns the context update scripts are rendered with for the given AppService and
+// Events Database credentials
+func NewEventsDatabaseScriptContext(instance *gramolav1.AppService, credentials map[string]string) EventsDatabaseScriptContext {
+	scriptContext := EventsDatabaseScriptContext{
+		DatabaseName:    credentials[EventsDatabaseNameKey],
+		DatabaseUser:    credentials[EventsDatabaseUserKey],
+		OperatorVersion: version.Version,
+		AppServiceName:  instance.Name,
+		Namespace:       instance.Namespace,
+		Location:        instance.Spec.Location,
+		Alias:           instance.Spec.Alias,
+		Variables:       map[string]string{},
+	}
+	if instance.Spec.Database != nil {
+		for name, value := range instance.Spec.Database.ScriptVariables {
+			scriptContext.Variables[name] = value
+		}
+	}
+
+	return scriptContext
+}
+
+// GetEventsDatabaseScriptsMap returns a KV map with script names as Ks and the rendered scripts as Vs
+func GetEventsDatabaseScriptsMap(updateScripts []EventsDatabaseUpdateScript, scriptContext EventsDatabaseScriptContext) (map[string]string, error) {
 	scripts := make(map[string]string)
-	databaseUser := DatabaseCredentials["database-user"]
 
-	if dbUpdateScriptData, err := util.ReadFile(DbScriptsBasePath, EventsDatabaseUpdateScriptName); err == nil {
-		dbUpdateScriptDataReplaced := strings.Replace(dbUpdateScriptData, "{{DB_USERNAME}}", databaseUser, -1)
-		scripts[EventsDatabaseUpdateScriptName] = dbUpdateScriptDataReplaced
+	for _, updateScript := range updateScripts {
+		dbUpdateScriptData, err := RenderEventsDatabaseUpdateScript(updateScript, scriptContext)
+		if err != nil {
+			return nil, err
+		}
+		scripts[updateScript.Name] = dbUpdateScriptData
+		if len(updateScript.DownName) > 0 {
+			dbDownScriptData, err := RenderEventsDatabaseDownScript(updateScript, scriptContext)
+			if err != nil {
+				return nil, err
+			}
+			scripts[updateScript.DownName] = dbDownScriptData
+		}
+	}
+
+	return scripts, nil
+}
+
+// RenderEventsDatabaseUpdateScript returns the update script as it's run against the database
+func RenderEventsDatabaseUpdateScript(updateScript EventsDatabaseUpdateScript, scriptContext EventsDatabaseScriptContext) (string, error) {
+	if len(updateScript.ConfigMap) > 0 {
+		return renderEventsDatabaseScript(updateScript.Name, updateScript.Data, scriptContext)
+	}
+	dbScriptData, err := util.ReadFile(DbScriptsBasePath, updateScript.Name)
+	if err != nil {
+		return "", err
+	}
+	return renderEventsDatabaseScript(updateScript.Name, dbScriptData, scriptContext)
+}
+
+// RenderEventsDatabaseDownScript returns the down script of the given update script ready to be run
+func RenderEventsDatabaseDownScript(updateScript EventsDatabaseUpdateScript, scriptContext EventsDatabaseScriptContext) (string, error) {
+	if len(updateScript.ConfigMap) > 0 {
+		return renderEventsDatabaseScript(updateScript.DownName, updateScript.DownData, scriptContext)
+	}
+	dbScriptData, err := util.ReadFile(DbScriptsBasePath, updateScript.DownName)
+	if err != nil {
+		return "", err
+	}
+	return renderEventsDatabaseScript(updateScript.DownName, dbScriptData, scriptContext)
+}
+
+// renderEventsDatabaseScript returns the given script rendered as a template with the given context
+func renderEventsDatabaseScript(scriptName string, dbScriptData string, scriptContext EventsDatabaseScriptContext) (string, error) {
+	scriptTemplate, err := template.New(scriptName).Option("missingkey=error").Funcs(template.FuncMap{
+		// Scripts written before they were templates refer to the user as {{DB_USERNAME}}
+		"DB_USERNAME": func() string { return scriptContext.DatabaseUser },
+	}).Parse(dbScriptData)
+	if err != nil {
+		return "", fmt.Errorf("Failed parsing script %s: %s", scriptName, err)
+	}
+
+	var script bytes.Buffer
+	if err := scriptTemplate.Execute(&script, scriptContext); err != nil {
+		return "", fmt.Errorf("Failed rendering script %s: %s", scriptName, err)
+	}
+
+	return script.String(), nil
+}
+
+// GetEventsDatabaseUpdateScriptChecksums returns the SHA-256 of every rendered update script by script name, so
+// changing a variable a script uses changes its checksum too
+func GetEventsDatabaseUpdateScriptChecksums(updateScripts []EventsDatabaseUpdateScript, scriptContext EventsDatabaseScriptContext) (map[string]string, error) {
+	checksums := map[string]string{}
+	for _, updateScript := range updateScripts {
+		dbUpdateScriptData, err := RenderEventsDatabaseUpdateScript(updateScript, scriptContext)
+		if err != nil {
+			return nil, err
+		}
+		checksum := sha256.Sum256([]byte(dbUpdateScriptData))
+		checksums[updateScript.Name] = hex.EncodeToString(checksum[:])
 	}
 
-	return scripts
+	return checksums, nil
+}
+
+// GetEventsDatabaseStorageSize returns the size requested for the Events Database volume
+func GetEventsDatabaseStorageSize(instance *gramolav1.AppService) resource.Quantity {
+	if instance.Spec.Database != nil && instance.Spec.Database.Storage != nil && instance.Spec.Database.Storage.Size != nil {
+		return *instance.Spec.Database.Storage.Size
+	}
+	return resource.MustParse(EventsDatabasePersistanceVolumeClaimSize)
 }
 
-// NewEventsDatabaseCredentialsSecret returns a Secret with the Events Database credentials
+// NewEventsDatabasePersistentVolumeClaim returns the PVC where the Events Database keeps its data as set in spec.database.storage
+func NewEventsDatabasePersistentVolumeClaim(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.PersistentVolumeClaim, error) {
+	pvc := newEventsDatabasePersistentVolumeClaim(instance, GetEventsDatabasePersistentVolumeClaimName(instance))
+
+	if err := controllerutil.SetControllerReference(instance, pvc, scheme); err != nil {
+		return nil, err
+	}
+
+	return pvc, nil
+}
+
+// newEventsDatabasePersistentVolumeClaim returns a PVC for the Events Database data as set in spec.database.storage
+func newEventsDatabasePersistentVolumeClaim(instance *gramolav1.AppService, name string) *corev1.PersistentVolumeClaim {
+	var storage *gramolav1.StorageSpec
+	if instance.Spec.Database != nil {
+		storage = instance.Spec.Database.Storage
+	}
+
+	return newPersistentVolumeClaimFromStorageSpec(instance, name, storage, GetEventsDatabaseStorageSize(instance))
+}
+
+// newPersistentVolumeClaimFromStorageSpec returns a PVC of the given size as set in storage, if any
+func newPersistentVolumeClaimFromStorageSpec(instance *gramolav1.AppService, name string, storage *gramolav1.StorageSpec, size resource.Quantity) *corev1.PersistentVolumeClaim {
+	pvc := NewPersistentVolumeClaim(instance, name, instance.Namespace, EventsDatabasePersistanceVolumeClaimSize)
+
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	if storage != nil {
+		pvc.Spec.StorageClassName = storage.StorageClassName
+		if len(storage.AccessModes) > 0 {
+			pvc.Spec.AccessModes = storage.AccessModes
+		}
+		pvc.Spec.VolumeMode = storage.VolumeMode
+	}
+
+	return pvc
+}
+
+// NewEventsDatabasePersistentVolumeClaimPatch returns a Patch that requests the given size, the rest of the spec can't be changed
+func NewEventsDatabasePersistentVolumeClaimPatch(current *corev1.PersistentVolumeClaim, size resource.Quantity) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Spec.Resources.Requests[corev1.ResourceStorage] = size
+
+	return patch
+}
+
+// NewEventsDatabaseCredentialsSecret returns a Secret with newly generated Events Database credentials
 func NewEventsDatabaseCredentialsSecret(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Secret, error) {
 	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName)
 
+	credentials, err := newEventsDatabaseCredentials()
+	if err != nil {
+		return nil, err
+	}
+
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
@@ -93,7 +439,7 @@ func NewEventsDatabaseCredentialsSecret(instance *gramolav1.AppService, scheme *
 			Namespace: instance.Namespace,
 			Labels:    labels,
 		},
-		StringData: DatabaseCredentials,
+		StringData: credentials,
 	}
 
 	if err := controllerutil.SetControllerReference(instance, secret, scheme); err != nil {
@@ -103,10 +449,13 @@ func NewEventsDatabaseCredentialsSecret(instance *gramolav1.AppService, scheme *
 	return secret, nil
 }
 
-// NewEventsDatabaseScriptsConfigMap returns a ConfigMap given a data object
-func NewEventsDatabaseScriptsConfigMap(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.ConfigMap, error) {
+// NewEventsDatabaseScriptsConfigMap returns a ConfigMap with the given update scripts rendered with the given context
+func NewEventsDatabaseScriptsConfigMap(instance *gramolav1.AppService, scheme *runtime.Scheme, updateScripts []EventsDatabaseUpdateScript, scriptContext EventsDatabaseScriptContext) (*corev1.ConfigMap, error) {
 	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName)
-	scripts := getDatabaseScriptsMap()
+	scripts, err := GetEventsDatabaseScriptsMap(updateScripts, scriptContext)
+	if err != nil {
+		return nil, err
+	}
 
 	configMap := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
@@ -129,12 +478,14 @@ func NewEventsDatabaseScriptsConfigMap(instance *gramolav1.AppService, scheme *r
 }
 
 // NewEventsDatabaseScriptsConfigMapPatch returns a Patch
-func NewEventsDatabaseScriptsConfigMapPatch(current *corev1.ConfigMap) client.Patch {
+func NewEventsDatabaseScriptsConfigMapPatch(current *corev1.ConfigMap, scripts map[string]string) client.Patch {
 	patch := client.MergeFrom(current.DeepCopy())
 
 	current.Labels["version"] = version.Version
 
-	scripts := getDatabaseScriptsMap()
+	if current.Data == nil {
+		current.Data = map[string]string{}
+	}
 	for k, v := range scripts {
 		current.Data[k] = v
 	}
@@ -142,35 +493,53 @@ func NewEventsDatabaseScriptsConfigMapPatch(current *corev1.ConfigMap) client.Pa
 	return patch
 }
 
-// NewEventsDatabaseCredentialsSecretPatch returns a Patch
+// NewEventsDatabaseCredentialsSecretPatch returns a Patch, credentials are never changed once the Secret exists
 func NewEventsDatabaseCredentialsSecretPatch(current *corev1.Secret) client.Patch {
 	patch := client.MergeFrom(current.DeepCopy())
 
 	current.Labels["version"] = version.Version
 
-	current.StringData = DatabaseCredentials
-
 	return patch
 }
 
 // NewEventsDatabaseDeploymentPatch returns a Patch
-func NewEventsDatabaseDeploymentPatch(current *appsv1.Deployment) client.Patch {
+func NewEventsDatabaseDeploymentPatch(current *appsv1.Deployment, instance *gramolav1.AppService) client.Patch {
 	patch := client.MergeFrom(current.DeepCopy())
 
-	// Nothing to be changed, we could add version label but that would restart the DB
+	// We could add version label but that would restart the DB
 	// current.Labels["version"] = version.Version
 
+	// Only turning replication on or off changes the command and the environment, and restarts the DB
+	syncEventsDatabasePodTemplate(&current.Spec.Template, instance)
+
 	return patch
 }
 
+// syncEventsDatabasePodTemplate updates the command and environment of the Events Database container of a pod template
+func syncEventsDatabasePodTemplate(current *corev1.PodTemplateSpec, instance *gramolav1.AppService) {
+	template := newEventsDatabasePodTemplate(instance, current.Labels)
+	for i := range current.Spec.Containers {
+		if current.Spec.Containers[i].Name == EventsDatabaseServiceContainerName {
+			current.Spec.Containers[i].Command = template.Spec.Containers[0].Command
+			current.Spec.Containers[i].Env = template.Spec.Containers[0].Env
+		}
+	}
+}
+
 // NewEventsDeploymentPatch returns a Patch
-func NewEventsDeploymentPatch(current *appsv1.Deployment) client.Patch {
+func NewEventsDeploymentPatch(current *appsv1.Deployment, instance *gramolav1.AppService, pooled bool) client.Patch {
 	patch := client.MergeFrom(current.DeepCopy())
 
 	current.Labels["version"] = version.Version
 
-	current.Spec.Replicas = &EventsServiceReplicas
+	// Replicas are managed by the restore or the database upgrade while they're in progress
+	_, restoring := current.Annotations[RestoreAnnotation]
+	_, upgrading := current.Annotations[DatabaseUpgradeAnnotation]
+	if !restoring && !upgrading {
+		current.Spec.Replicas = &EventsServiceReplicas
+	}
 	current.Spec.Template.Spec.Containers[0].Image = EventsServiceImage
+	current.Spec.Template.Spec.Containers[0].Env = newEventsEnv(instance, pooled)
 
 	current.Spec.Template.Spec.Containers[0].ReadinessProbe = &corev1.Probe{
 		Handler: corev1.Handler{
@@ -238,20 +607,17 @@ func NewEventsRoutePatch(current *routev1.Route) client.Patch {
 	return patch
 }
 
-// NewEventsDeployment returns the deployment object for Events
-func NewEventsDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme) (*appsv1.Deployment, error) {
-	annotations := GetEventsAnnotations(instance)
-	labels := GetAppServiceLabels(instance, EventsServiceName)
-	labels["app.kubernetes.io/name"] = "java"
-
-	env := []corev1.EnvVar{
+// newEventsEnv returns the environment variables of the Events Service, which point to the Events Database or to its
+// pooler if pooled is set
+func newEventsEnv(instance *gramolav1.AppService, pooled bool) []corev1.EnvVar {
+	return []corev1.EnvVar{
 		{
 			Name: "DB_USERNAME",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-user",
+					Key: EventsDatabaseUserKey,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
 					},
 				},
 			},
@@ -260,33 +626,32 @@ func NewEventsDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme)
 			Name: "DB_PASSWORD",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-password",
+					Key: EventsDatabasePasswordKey,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
 					},
 				},
 			},
 		},
+		newEventsDatabaseNameEnvVar(instance, "DB_NAME"),
 		{
-			Name: "DB_NAME",
-			ValueFrom: &corev1.EnvVarSource{
-				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-name",
-					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
-					},
-				},
-			},
-		},
-		{
-			Name:  "DB_SERVICE_NAME",
-			Value: EventsDatabaseServiceName,
+			Name:  EventsDatabaseHostEnvVarName,
+			Value: GetEventsDatabaseClientHost(instance, pooled),
 		},
 		{
 			Name:  "DB_SERVICE_PORT",
-			Value: strconv.Itoa(EventsDatabaseServicePort),
+			Value: strconv.Itoa(GetEventsDatabaseClientPort(instance, pooled)),
 		},
 	}
+}
+
+// NewEventsDeployment returns the deployment object for Events, connected to the pooler if pooled is set
+func NewEventsDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme, pooled bool) (*appsv1.Deployment, error) {
+	annotations := GetEventsAnnotations(instance)
+	labels := GetAppServiceLabels(instance, EventsServiceName)
+	labels["app.kubernetes.io/name"] = "java"
+
+	env := newEventsEnv(instance, pooled)
 
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
@@ -377,19 +742,16 @@ func NewEventsDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme)
 	return deployment, nil
 }
 
-// NewEventsDatabaseDeployment returns the DB deployment for Events
-func NewEventsDatabaseDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme) (*appsv1.Deployment, error) {
-	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName)
-	labels["app.kubernetes.io/name"] = "postgresql"
-
+// newEventsDatabasePodTemplate returns the pod template of the Events Database, the data volume is the Events Database PVC
+func newEventsDatabasePodTemplate(instance *gramolav1.AppService, labels map[string]string) corev1.PodTemplateSpec {
 	env := []corev1.EnvVar{
 		{
 			Name: "POSTGRESQL_USER",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-user",
+					Key: EventsDatabaseUserKey,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
 					},
 				},
 			},
@@ -398,9 +760,9 @@ func NewEventsDatabaseDeployment(instance *gramolav1.AppService, scheme *runtime
 			Name: "POSTGRESQL_PASSWORD",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-password",
+					Key: EventsDatabasePasswordKey,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
 					},
 				},
 			},
@@ -409,111 +771,101 @@ func NewEventsDatabaseDeployment(instance *gramolav1.AppService, scheme *runtime
 			Name: "POSTGRESQL_DATABASE",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					Key: "database-name",
+					Key: EventsDatabaseNameKey,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: EventsDatabaseCredentialsSecretName,
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
 					},
 				},
 			},
 		},
 	}
 
-	deployment := &appsv1.Deployment{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Deployment",
-			APIVersion: "apps/v1",
-		},
+	// The primary lets the replicas stream from it with the replication credentials
+	var command []string
+	if IsEventsDatabaseReplicated(instance) {
+		command = []string{"run-postgresql-master"}
+		env = append(env, newEventsDatabaseReplicationEnv("POSTGRESQL_MASTER_USER", "POSTGRESQL_MASTER_PASSWORD")...)
+	}
+
+	return corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      EventsDatabaseServiceName,
-			Namespace: instance.Namespace,
-			Labels:    labels,
+			Labels: labels,
 		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &EventsDatabaseServiceReplicas,
-			Selector: &metav1.LabelSelector{MatchLabels: labels},
-			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RecreateDeploymentStrategyType,
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            EventsDatabaseServiceContainerName,
+					Image:           GetEventsDatabaseImage(instance),
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Command:         command,
+					Ports: []corev1.ContainerPort{
 						{
-							Name:            EventsDatabaseServiceContainerName,
-							Image:           EventsDatabaseServiceImage,
-							ImagePullPolicy: corev1.PullIfNotPresent,
-							Ports: []corev1.ContainerPort{
-								{
-									Name:          EventsDatabaseServicePortName,
-									ContainerPort: EventsDatabaseServicePort,
-									Protocol:      "TCP",
-								},
-							},
-							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceMemory: resource.MustParse("512Mi"),
-								},
-								Limits: corev1.ResourceList{
-									corev1.ResourceMemory: resource.MustParse("512Mi"),
-								},
-							},
-							ReadinessProbe: &corev1.Probe{
-								Handler: corev1.Handler{
-									Exec: &corev1.ExecAction{
-										Command: []string{
-											"/usr/libexec/check-container",
-										},
-									},
-								},
-								InitialDelaySeconds: 5,
-								FailureThreshold:    3,
-								TimeoutSeconds:      1,
-							},
-							LivenessProbe: &corev1.Probe{
-								Handler: corev1.Handler{
-									Exec: &corev1.ExecAction{
-										Command: []string{
-											"/usr/libexec/check-container",
-											"--live",
-										},
-									},
+							Name:          EventsDatabaseServicePortName,
+							ContainerPort: EventsDatabaseServicePort,
+							Protocol:      "TCP",
+						},
+					},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceMemory: resource.MustParse("512Mi"),
+						},
+						Limits: corev1.ResourceList{
+							corev1.ResourceMemory: resource.MustParse("512Mi"),
+						},
+					},
+					ReadinessProbe: &corev1.Probe{
+						Handler: corev1.Handler{
+							Exec: &corev1.ExecAction{
+								Command: []string{
+									"/usr/libexec/check-container",
 								},
-								InitialDelaySeconds: 120,
-								FailureThreshold:    3,
-								TimeoutSeconds:      10,
 							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      EventsDatabasePersistanceVolumeName,
-									MountPath: "/var/lib/pgsql/data",
-								},
-								{
-									Name:      EventsDatabaseScriptsConfigMapName,
-									MountPath: EventsDatabaseScriptsMountPath,
+						},
+						InitialDelaySeconds: 5,
+						FailureThreshold:    3,
+						TimeoutSeconds:      1,
+					},
+					LivenessProbe: &corev1.Probe{
+						Handler: corev1.Handler{
+							Exec: &corev1.ExecAction{
+								Command: []string{
+									"/usr/libexec/check-container",
+									"--live",
 								},
 							},
-							Env: env,
 						},
+						InitialDelaySeconds: 120,
+						FailureThreshold:    3,
+						TimeoutSeconds:      10,
 					},
-					Volumes: []corev1.Volume{
+					VolumeMounts: []corev1.VolumeMount{
 						{
-							Name: EventsDatabasePersistanceVolumeName,
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: EventsDatabasePersistanceVolumeClaimName,
-								},
-							},
+							Name:      EventsDatabasePersistanceVolumeName,
+							MountPath: EventsDatabaseDataMountPath,
 						},
 						{
-							Name: EventsDatabaseScriptsConfigMapName,
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: EventsDatabaseScriptsConfigMapName,
-									},
-								},
+							Name:      EventsDatabaseScriptsConfigMapName,
+							MountPath: EventsDatabaseScriptsMountPath,
+						},
+					},
+					Env: env,
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: EventsDatabasePersistanceVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: GetEventsDatabasePersistentVolumeClaimName(instance),
+						},
+					},
+				},
+				{
+					Name: EventsDatabaseScriptsConfigMapName,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: EventsDatabaseScriptsConfigMapName,
 							},
 						},
 					},
@@ -521,6 +873,34 @@ func NewEventsDatabaseDeployment(instance *gramolav1.AppService, scheme *runtime
 			},
 		},
 	}
+}
+
+// NewEventsDatabaseDeployment returns the DB deployment for Events
+func NewEventsDatabaseDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme) (*appsv1.Deployment, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName)
+	labels["app.kubernetes.io/name"] = "postgresql"
+
+	replicas := GetEventsDatabasePrimaryReplicas(instance)
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabaseServiceName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RecreateDeploymentStrategyType,
+			},
+			Template: newEventsDatabasePodTemplate(instance, labels),
+		},
+	}
 
 	if err := controllerutil.SetControllerReference(instance, deployment, scheme); err != nil {
 		return nil, err
@@ -0,0 +1,67 @@
+package deployment
+
+import (
+	"testing"
+)
+
+func TestRenderEventsDatabaseScript(t *testing.T) {
+	scriptContext := EventsDatabaseScriptContext{
+		DatabaseName: "eventsdb",
+		DatabaseUser: "user1",
+		Variables:    map[string]string{"schema": "reporting"},
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "no template",
+			script: "CREATE TABLE event (id INT);",
+			want:   "CREATE TABLE event (id INT);",
+		},
+		{
+			name:   "context fields",
+			script: "GRANT ALL ON DATABASE {{.DatabaseName}} TO {{.DatabaseUser}};",
+			want:   "GRANT ALL ON DATABASE eventsdb TO user1;",
+		},
+		{
+			name:   "legacy user function",
+			script: "ALTER TABLE event OWNER TO {{DB_USERNAME}};",
+			want:   "ALTER TABLE event OWNER TO user1;",
+		},
+		{
+			name:   "script variable",
+			script: "CREATE SCHEMA {{.Variables.schema}};",
+			want:   "CREATE SCHEMA reporting;",
+		},
+		{
+			name:    "missing script variable",
+			script:  "CREATE SCHEMA {{.Variables.missing}};",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			script:  "CREATE SCHEMA {{.Schema}};",
+			wantErr: true,
+		},
+		{
+			name:    "invalid template",
+			script:  "CREATE SCHEMA {{.Variables.schema;",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := renderEventsDatabaseScript("events-database-update-0.0.3.sql", test.script, scriptContext)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("renderEventsDatabaseScript() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("renderEventsDatabaseScript() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package deployment
+
+import (
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// StatefulSetPodNameLabel is set by the StatefulSet controller on every pod it creates
+const StatefulSetPodNameLabel = "statefulset.kubernetes.io/pod-name"
+
+// GetEventsDatabasePromotedPrimary returns the replica pod promoted to primary, empty if the original primary serves
+func GetEventsDatabasePromotedPrimary(instance *gramolav1.AppService) string {
+	if instance.Status.Database == nil {
+		return ""
+	}
+	return instance.Status.Database.Primary
+}
+
+// GetEventsDatabasePrimaryReplicas returns the replicas of the original primary, once fenced by a failover it stays down
+func GetEventsDatabasePrimaryReplicas(instance *gramolav1.AppService) int32 {
+	if len(GetEventsDatabasePromotedPrimary(instance)) > 0 {
+		return 0
+	}
+	return EventsDatabaseServiceReplicas
+}
+
+// EventsDatabaseReplicaOrdinal returns the ordinal of a pod of the replicas StatefulSet, false if it isn't one
+func EventsDatabaseReplicaOrdinal(podName string) (int, bool) {
+	prefix := EventsDatabaseReplicaName + "-"
+	if !strings.HasPrefix(podName, prefix) {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(podName, prefix))
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return ordinal, true
+}
+
+// NewEventsDatabaseStatefulSetScalePatch returns a Patch that scales the Events Database StatefulSet to the given replicas
+func NewEventsDatabaseStatefulSetScalePatch(current *appsv1.StatefulSet, replicas int32) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Spec.Replicas = &replicas
+
+	return patch
+}
+
+// NewEventsDatabasePromotePatch returns a Patch that adds the trigger file of the given replica pod to the promote ConfigMap
+func NewEventsDatabasePromotePatch(current *corev1.ConfigMap, podName string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	if current.Data == nil {
+		current.Data = map[string]string{}
+	}
+	current.Data[podName] = "promote"
+
+	return patch
+}
+
+// NewEventsDatabaseServicePrimaryPatch returns a Patch that points the Events Database Service to the given pod only
+func NewEventsDatabaseServicePrimaryPatch(current *corev1.Service, podName string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Spec.Selector = map[string]string{
+		StatefulSetPodNameLabel: podName,
+	}
+
+	return patch
+}
@@ -0,0 +1,132 @@
+package deployment
+
+import (
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database Jobs names
+const (
+	EventsDatabaseJobContainerName = "psql"
+)
+
+// eventsDatabasePsqlCommand runs psql so that it exits with an error on the first failing statement and reports the
+// SQLSTATE of the error along with its message
+const eventsDatabasePsqlCommand = "psql -v ON_ERROR_STOP=1 -v VERBOSITY=verbose"
+
+// EventsDatabaseJobBackoffLimit number of retries before considering an Events Database Job failed
+var EventsDatabaseJobBackoffLimit = int32(3)
+
+// newEventsDatabaseClientEnv returns the libpq environment variables needed to connect to the Events Database
+func newEventsDatabaseClientEnv(instance *gramolav1.AppService) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{
+			Name:  "PGHOST",
+			Value: GetEventsDatabaseHost(instance),
+		},
+		{
+			Name:  "PGPORT",
+			Value: strconv.Itoa(GetEventsDatabasePort(instance)),
+		},
+		{
+			Name: "PGUSER",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: EventsDatabaseUserKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
+					},
+				},
+			},
+		},
+		{
+			Name: "PGPASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: EventsDatabasePasswordKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: GetEventsDatabaseCredentialsSecretName(instance),
+					},
+				},
+			},
+		},
+		newEventsDatabaseNameEnvVar(instance, "PGDATABASE"),
+		{
+			Name:  "PGSSLMODE",
+			Value: string(GetEventsDatabaseSSLMode(instance)),
+		},
+	}
+	if len(GetEventsDatabaseCASecretName(instance)) > 0 {
+		env = append(env, corev1.EnvVar{
+			Name:  "PGSSLROOTCERT",
+			Value: EventsDatabaseCACertMountPath + "/" + EventsDatabaseCACertKey,
+		})
+	}
+
+	return env
+}
+
+// newEventsDatabaseJob returns a Job that runs the given command in a container with the Events Database image
+// and the environment to connect to the database
+func newEventsDatabaseJob(instance *gramolav1.AppService, name string, labels map[string]string, command string, volumes []corev1.Volume, volumeMounts []corev1.VolumeMount) *batchv1.Job {
+	// The certificate of an external database is checked against its authority
+	if caSecretName := GetEventsDatabaseCASecretName(instance); len(caSecretName) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: EventsDatabaseCACertVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: caSecretName,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      EventsDatabaseCACertVolumeName,
+			MountPath: EventsDatabaseCACertMountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &EventsDatabaseJobBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:            EventsDatabaseJobContainerName,
+							Image:           GetEventsDatabaseImage(instance),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command: []string{
+								"/bin/bash",
+								"-c",
+								command,
+							},
+							VolumeMounts: volumeMounts,
+							Env:          newEventsDatabaseClientEnv(instance),
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
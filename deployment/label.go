@@ -8,6 +8,12 @@ import (
 // Label Consts
 const (
 	AppName = "gramola"
+
+	// AppServiceNameLabel points to the AppService of resources it doesn't control directly, i.e. Jobs spawned by a CronJob
+	AppServiceNameLabel = "gramola.atarazana.com/appservice"
+
+	// EventsDatabaseRoleLabel tells the primary Events Database pod from the replicas
+	EventsDatabaseRoleLabel = "gramola.atarazana.com/role"
 )
 
 // GetAppServiceLabels returns a map with the labels we want for all AppService assets
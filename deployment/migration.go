@@ -0,0 +1,25 @@
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// GetMigrationMode returns how pending update scripts should be run, Auto if not set
+func GetMigrationMode(instance *gramolav1.AppService) gramolav1.MigrationMode {
+	if instance.Spec.Database == nil || len(instance.Spec.Database.MigrationMode) <= 0 {
+		return gramolav1.MigrationModeAuto
+	}
+	return instance.Spec.Database.MigrationMode
+}
+
+// EventsDatabaseDryRunChecksumFor returns the checksum that identifies the dry run of the scripts with the given
+// checksums, so that a new dry run is done whenever the pending scripts change
+func EventsDatabaseDryRunChecksumFor(checksums []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(checksums, ",")))
+	return hex.EncodeToString(sum[:])
+}
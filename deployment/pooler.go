@@ -0,0 +1,310 @@
+package deployment
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	version "github.com/atarazana/gramola-operator/version"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database pooler names
+const (
+	EventsDatabasePoolerName          = EventsDatabaseServiceName + "-pooler"
+	EventsDatabasePoolerContainerName = "pgbouncer"
+	EventsDatabasePoolerImage         = "edoburu/pgbouncer:1.15.0"
+	EventsDatabasePoolerPort          = 5432
+	EventsDatabasePoolerConfigKey     = "pgbouncer.ini"
+	EventsDatabasePoolerUserListKey   = "userlist.txt"
+	EventsDatabasePoolerMountPath     = "/etc/pgbouncer"
+	eventsDatabasePoolerVolumeName    = "config"
+)
+
+// Events Database pooler defaults
+const (
+	EventsDatabasePoolerDefaultPoolSize      = 20
+	EventsDatabasePoolerMaxClientConnections = 100
+)
+
+// EventsDatabasePoolerReplicas number of replicas of the pooler if not set in the spec
+var EventsDatabasePoolerReplicas = int32(1)
+
+// IsEventsDatabasePooled returns true if the Events Service should connect through PgBouncer
+func IsEventsDatabasePooled(instance *gramolav1.AppService) bool {
+	return instance.Spec.Database != nil && instance.Spec.Database.Pooler != nil
+}
+
+// GetEventsDatabaseClientHost returns the host the Events Service connects to, the pooler if it connects through it
+func GetEventsDatabaseClientHost(instance *gramolav1.AppService, pooled bool) string {
+	if pooled {
+		return EventsDatabasePoolerName
+	}
+	return GetEventsDatabaseHost(instance)
+}
+
+// GetEventsDatabaseClientPort returns the port the Events Service connects to, the pooler's if it connects through it
+func GetEventsDatabaseClientPort(instance *gramolav1.AppService, pooled bool) int {
+	if pooled {
+		return EventsDatabasePoolerPort
+	}
+	return GetEventsDatabasePort(instance)
+}
+
+// IsEventsDeploymentPooled returns true if the given Events Deployment connects through the pooler
+func IsEventsDeploymentPooled(current *appsv1.Deployment) bool {
+	for _, container := range current.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == EventsDatabaseHostEnvVarName && env.Value == EventsDatabasePoolerName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getEventsDatabasePoolerReplicas returns the replicas of the pooler
+func getEventsDatabasePoolerReplicas(instance *gramolav1.AppService) int32 {
+	if instance.Spec.Database.Pooler.Replicas != nil {
+		return *instance.Spec.Database.Pooler.Replicas
+	}
+	return EventsDatabasePoolerReplicas
+}
+
+// newEventsDatabasePoolerConfig returns the pgbouncer.ini and userlist.txt of the pooler for the given credentials.
+// The user list keeps the MD5 hash of the password, which PgBouncer also uses to log into the database
+func newEventsDatabasePoolerConfig(instance *gramolav1.AppService, credentials map[string]string) map[string]string {
+	pooler := instance.Spec.Database.Pooler
+	poolMode := gramolav1.PoolModeTransaction
+	if len(pooler.PoolMode) > 0 {
+		poolMode = pooler.PoolMode
+	}
+	defaultPoolSize := int32(EventsDatabasePoolerDefaultPoolSize)
+	if pooler.DefaultPoolSize > 0 {
+		defaultPoolSize = pooler.DefaultPoolSize
+	}
+	maxClientConnections := int32(EventsDatabasePoolerMaxClientConnections)
+	if pooler.MaxClientConnections > 0 {
+		maxClientConnections = pooler.MaxClientConnections
+	}
+
+	user := credentials[EventsDatabaseUserKey]
+	databaseName := credentials[EventsDatabaseNameKey]
+	config := []string{
+		"[databases]",
+		fmt.Sprintf("%s = host=%s port=%d dbname=%s", databaseName, GetEventsDatabaseHost(instance), GetEventsDatabasePort(instance), databaseName),
+		"",
+		"[pgbouncer]",
+		"listen_addr = 0.0.0.0",
+		fmt.Sprintf("listen_port = %d", EventsDatabasePoolerPort),
+		"auth_type = md5",
+		"auth_file = " + EventsDatabasePoolerMountPath + "/" + EventsDatabasePoolerUserListKey,
+		"pool_mode = " + strings.ToLower(string(poolMode)),
+		fmt.Sprintf("default_pool_size = %d", defaultPoolSize),
+		fmt.Sprintf("max_client_conn = %d", maxClientConnections),
+		// Set by the PostgreSQL JDBC driver on connect
+		"ignore_startup_parameters = extra_float_digits",
+		"",
+	}
+	passwordHash := md5.Sum([]byte(credentials[EventsDatabasePasswordKey] + user))
+
+	return map[string]string{
+		EventsDatabasePoolerConfigKey:   strings.Join(config, "\n"),
+		EventsDatabasePoolerUserListKey: fmt.Sprintf("\"%s\" \"md5%x\"\n", user, passwordHash),
+	}
+}
+
+// GetEventsDatabasePoolerConfigChecksum returns the SHA-256 of the pooler configuration, it's set in the pod template
+// so that the pooler is restarted when the configuration or the credentials change
+func GetEventsDatabasePoolerConfigChecksum(config map[string]string) string {
+	checksum := sha256.Sum256([]byte(config[EventsDatabasePoolerConfigKey] + config[EventsDatabasePoolerUserListKey]))
+	return fmt.Sprintf("%x", checksum)
+}
+
+// NewEventsDatabasePoolerSecret returns the Secret with the configuration of the pooler generated from the given credentials
+func NewEventsDatabasePoolerSecret(instance *gramolav1.AppService, scheme *runtime.Scheme, credentials map[string]string) (*corev1.Secret, error) {
+	secret := NewSecretFromStringData(instance, EventsDatabasePoolerName, instance.Namespace, newEventsDatabasePoolerConfig(instance, credentials))
+
+	if err := controllerutil.SetControllerReference(instance, secret, scheme); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// NewEventsDatabasePoolerSecretPatch returns a Patch that regenerates the configuration of the pooler
+func NewEventsDatabasePoolerSecretPatch(current *corev1.Secret, config map[string]string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Labels["version"] = version.Version
+	current.Data = map[string][]byte{}
+	for key, value := range config {
+		current.Data[key] = []byte(value)
+	}
+
+	return patch
+}
+
+// NewEventsDatabasePoolerDeployment returns the Deployment of PgBouncer, it mounts the configuration Secret
+func NewEventsDatabasePoolerDeployment(instance *gramolav1.AppService, scheme *runtime.Scheme, checksum string) (*appsv1.Deployment, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabasePoolerName)
+	labels["app.kubernetes.io/name"] = "pgbouncer"
+
+	replicas := getEventsDatabasePoolerReplicas(instance)
+
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt(EventsDatabasePoolerPort),
+			},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       10,
+		FailureThreshold:    3,
+		TimeoutSeconds:      1,
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabasePoolerName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				"app.openshift.io/connects-to": EventsDatabaseServiceName,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					Annotations: map[string]string{
+						PoolerConfigChecksumAnnotation: checksum,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            EventsDatabasePoolerContainerName,
+							Image:           EventsDatabasePoolerImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          EventsDatabaseServicePortName,
+									ContainerPort: EventsDatabasePoolerPort,
+									Protocol:      "TCP",
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceMemory: resource.MustParse("128Mi"),
+								},
+							},
+							ReadinessProbe: probe,
+							LivenessProbe:  probe,
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      eventsDatabasePoolerVolumeName,
+									MountPath: EventsDatabasePoolerMountPath,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: eventsDatabasePoolerVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: EventsDatabasePoolerName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, deployment, scheme); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// NewEventsDatabasePoolerDeploymentPatch returns a Patch, a new configuration checksum rolls out the pooler
+func NewEventsDatabasePoolerDeploymentPatch(current *appsv1.Deployment, instance *gramolav1.AppService, checksum string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Labels["version"] = version.Version
+
+	replicas := getEventsDatabasePoolerReplicas(instance)
+	current.Spec.Replicas = &replicas
+	current.Spec.Template.Spec.Containers[0].Image = EventsDatabasePoolerImage
+	if current.Spec.Template.Annotations == nil {
+		current.Spec.Template.Annotations = map[string]string{}
+	}
+	current.Spec.Template.Annotations[PoolerConfigChecksumAnnotation] = checksum
+
+	return patch
+}
+
+// NewEventsDatabasePoolerService returns the Service the Events Service connects to when the pooler is enabled
+func NewEventsDatabasePoolerService(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Service, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabasePoolerName)
+	selector := GetAppServiceLabels(instance, EventsDatabasePoolerName)
+	selector["app.kubernetes.io/name"] = "pgbouncer"
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabasePoolerName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       EventsDatabaseServicePortName,
+					Port:       EventsDatabasePoolerPort,
+					TargetPort: intstr.FromString(EventsDatabaseServicePortName),
+					Protocol:   "TCP",
+				},
+			},
+			Selector: selector,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, service, scheme); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
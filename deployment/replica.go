@@ -0,0 +1,374 @@
+package deployment
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+	version "github.com/atarazana/gramola-operator/version"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database replicas names
+const (
+	EventsDatabaseReplicaName             = EventsDatabaseServiceName + "-replica"
+	EventsDatabaseReadOnlyServiceName     = EventsDatabaseServiceName + "-readonly"
+	EventsDatabaseReplicationSecretName   = EventsDatabaseServiceName + "-replication"
+	EventsDatabasePromoteConfigMapName    = EventsDatabaseServiceName + "-promote"
+	EventsDatabasePromoteMountPath        = "/operator/promote"
+	EventsDatabaseReplicationUserKey      = "replication-user"
+	EventsDatabaseReplicationPasswordKey  = "replication-password"
+	EventsDatabaseReplicationUser         = "replicator"
+	eventsDatabasePromoteVolumeName       = "promote"
+	eventsDatabaseReplicaBootstrapCommand = `set -e
+source "${CONTAINER_SCRIPTS_PATH}/common.sh"
+generate_passwd_file
+export PGDATA=` + EventsDatabaseDataMountPath + `/userdata
+# A promoted replica keeps serving as primary
+if [ ! -f "` + EventsDatabasePromoteMountPath + `/${POD_NAME}" ]; then
+  if [ ! -s "${PGDATA}/PG_VERSION" ]; then
+    until pg_isready -h "${PRIMARY_HOST}" -p "${PRIMARY_PORT}"; do sleep 2; done
+    rm -rf "${PGDATA}"
+    PGPASSWORD="${REPLICATION_PASSWORD}" pg_basebackup -h "${PRIMARY_HOST}" -p "${PRIMARY_PORT}" -U "${REPLICATION_USER}" -D "${PGDATA}" -X stream
+    chmod 0700 "${PGDATA}"
+  fi
+  conninfo="host=${PRIMARY_HOST} port=${PRIMARY_PORT} user=${REPLICATION_USER} password=${REPLICATION_PASSWORD} application_name=${POD_NAME}"
+  # PostgreSQL 12 and later refuse to start with a recovery.conf, standbys are set in postgresql.auto.conf instead
+  if [ "$(cat "${PGDATA}/PG_VERSION")" -ge 12 ]; then
+    rm -f "${PGDATA}/recovery.conf"
+    sed -i '/^primary_conninfo\|^promote_trigger_file\|^recovery_target_timeline/d' "${PGDATA}/postgresql.auto.conf"
+    cat >> "${PGDATA}/postgresql.auto.conf" <<EOF
+primary_conninfo = '${conninfo}'
+promote_trigger_file = '` + EventsDatabasePromoteMountPath + `/${POD_NAME}'
+recovery_target_timeline = 'latest'
+EOF
+    touch "${PGDATA}/standby.signal"
+  else
+    cat > "${PGDATA}/recovery.conf" <<EOF
+standby_mode = 'on'
+primary_conninfo = '${conninfo}'
+trigger_file = '` + EventsDatabasePromoteMountPath + `/${POD_NAME}'
+recovery_target_timeline = 'latest'
+EOF
+  fi
+fi
+exec postgres -D "${PGDATA}"`
+)
+
+// Role label values of the Events Database pods
+const (
+	EventsDatabaseRolePrimary = "primary"
+	EventsDatabaseRoleReplica = "replica"
+)
+
+// IsEventsDatabaseReplicated returns true if the Events Database should have streaming replication standbys
+func IsEventsDatabaseReplicated(instance *gramolav1.AppService) bool {
+	return instance.Spec.Database != nil && instance.Spec.Database.Replicas > 0 && !IsEventsDatabaseExternal(instance)
+}
+
+// EventsDatabaseReplicaPersistentVolumeClaimNameFor returns the name of the PVC the replicas StatefulSet creates for the given pod ordinal
+func EventsDatabaseReplicaPersistentVolumeClaimNameFor(ordinal int) string {
+	return EventsDatabasePersistanceVolumeName + "-" + EventsDatabaseReplicaName + "-" + strconv.Itoa(ordinal)
+}
+
+// GetEventsDatabaseReplicas returns the number of pods of the replicas StatefulSet, a promoted replica is never scaled down
+func GetEventsDatabaseReplicas(instance *gramolav1.AppService) int32 {
+	if !IsEventsDatabaseReplicated(instance) {
+		return 0
+	}
+	replicas := instance.Spec.Database.Replicas
+	if ordinal, ok := EventsDatabaseReplicaOrdinal(GetEventsDatabasePromotedPrimary(instance)); ok && int32(ordinal) >= replicas {
+		replicas = int32(ordinal) + 1
+	}
+	return replicas
+}
+
+// newEventsDatabaseReplicationEnv returns the environment variables with the replication credentials
+func newEventsDatabaseReplicationEnv(userEnvVarName string, passwordEnvVarName string) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name: userEnvVarName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: EventsDatabaseReplicationUserKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: EventsDatabaseReplicationSecretName,
+					},
+				},
+			},
+		},
+		{
+			Name: passwordEnvVarName,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key: EventsDatabaseReplicationPasswordKey,
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: EventsDatabaseReplicationSecretName,
+					},
+				},
+			},
+		},
+	}
+}
+
+// NewEventsDatabaseReplicationSecret returns a Secret with newly generated credentials for the replicas to stream from the primary
+func NewEventsDatabaseReplicationSecret(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Secret, error) {
+	password, err := util.RandomString(EventsDatabaseGeneratedPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := NewSecretFromStringData(instance, EventsDatabaseReplicationSecretName, instance.Namespace, map[string]string{
+		EventsDatabaseReplicationUserKey:     EventsDatabaseReplicationUser,
+		EventsDatabaseReplicationPasswordKey: password,
+	})
+
+	if err := controllerutil.SetControllerReference(instance, secret, scheme); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// NewEventsDatabasePromoteConfigMap returns the ConfigMap mounted by the replicas, a key named after a replica pod
+// is the trigger file that promotes it
+func NewEventsDatabasePromoteConfigMap(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.ConfigMap, error) {
+	configMap := NewConfigMapFromData(instance, EventsDatabasePromoteConfigMapName, instance.Namespace, map[string]string{})
+
+	if err := controllerutil.SetControllerReference(instance, configMap, scheme); err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
+
+// NewEventsDatabaseReadOnlyService returns the Service that balances read-only traffic among the replicas
+func NewEventsDatabaseReadOnlyService(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Service, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseReplicaName)
+	selector := GetAppServiceLabels(instance, EventsDatabaseReplicaName)
+	selector[EventsDatabaseRoleLabel] = EventsDatabaseRoleReplica
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabaseReadOnlyServiceName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:     EventsDatabaseServicePortName,
+					Port:     EventsDatabaseServicePort,
+					Protocol: "TCP",
+				},
+			},
+			Selector: selector,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, service, scheme); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// NewEventsDatabaseReplicaHeadlessService returns the headless Service that governs the replicas StatefulSet
+func NewEventsDatabaseReplicaHeadlessService(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Service, error) {
+	service, err := NewEventsDatabaseReadOnlyService(instance, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	service.Name = EventsDatabaseReplicaName
+	service.Spec.Selector = GetAppServiceLabels(instance, EventsDatabaseReplicaName)
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	service.Spec.PublishNotReadyAddresses = true
+
+	return service, nil
+}
+
+// NewEventsDatabaseReplicaStatefulSet returns the StatefulSet of the standbys, each one bootstraps its volume with a
+// base backup of the primary and then streams from it
+func NewEventsDatabaseReplicaStatefulSet(instance *gramolav1.AppService, scheme *runtime.Scheme) (*appsv1.StatefulSet, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseReplicaName)
+	labels["app.kubernetes.io/name"] = "postgresql"
+
+	replicas := GetEventsDatabaseReplicas(instance)
+
+	env := []corev1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.name",
+				},
+			},
+		},
+		{
+			Name:  "PRIMARY_HOST",
+			Value: EventsDatabaseServiceName,
+		},
+		{
+			Name:  "PRIMARY_PORT",
+			Value: strconv.Itoa(EventsDatabaseServicePort),
+		},
+	}
+	env = append(env, newEventsDatabaseReplicationEnv("REPLICATION_USER", "REPLICATION_PASSWORD")...)
+
+	probe := &corev1.Probe{
+		Handler: corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{
+					"pg_isready",
+					"-h",
+					"127.0.0.1",
+					"-p",
+					strconv.Itoa(EventsDatabaseServicePort),
+				},
+			},
+		},
+		InitialDelaySeconds: 5,
+		FailureThreshold:    3,
+		TimeoutSeconds:      1,
+	}
+	livenessProbe := probe.DeepCopy()
+	// The base backup may take a while
+	livenessProbe.InitialDelaySeconds = 300
+	livenessProbe.TimeoutSeconds = 10
+
+	volumeClaimTemplate := newEventsDatabasePersistentVolumeClaim(instance, EventsDatabasePersistanceVolumeName)
+
+	statefulSet := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StatefulSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabaseReplicaName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &replicas,
+			Selector:            &metav1.LabelSelector{MatchLabels: labels},
+			ServiceName:         EventsDatabaseReplicaName,
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: &EventsDatabaseTerminationGracePeriodSeconds,
+					Containers: []corev1.Container{
+						{
+							Name:            EventsDatabaseServiceContainerName,
+							Image:           GetEventsDatabaseImage(instance),
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Command: []string{
+								"/bin/bash",
+								"-c",
+								eventsDatabaseReplicaBootstrapCommand,
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          EventsDatabaseServicePortName,
+									ContainerPort: EventsDatabaseServicePort,
+									Protocol:      "TCP",
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceMemory: resource.MustParse("512Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceMemory: resource.MustParse("512Mi"),
+								},
+							},
+							ReadinessProbe: probe,
+							LivenessProbe:  livenessProbe,
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      EventsDatabasePersistanceVolumeName,
+									MountPath: EventsDatabaseDataMountPath,
+								},
+								{
+									Name:      eventsDatabasePromoteVolumeName,
+									MountPath: EventsDatabasePromoteMountPath,
+								},
+							},
+							Env: env,
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: eventsDatabasePromoteVolumeName,
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{
+										Name: EventsDatabasePromoteConfigMapName,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   volumeClaimTemplate.Name,
+						Labels: volumeClaimTemplate.Labels,
+					},
+					Spec: volumeClaimTemplate.Spec,
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, statefulSet, scheme); err != nil {
+		return nil, err
+	}
+
+	return statefulSet, nil
+}
+
+// NewEventsDatabaseReplicaStatefulSetPatch returns a Patch
+func NewEventsDatabaseReplicaStatefulSetPatch(current *appsv1.StatefulSet, instance *gramolav1.AppService) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Labels["version"] = version.Version
+
+	replicas := GetEventsDatabaseReplicas(instance)
+	current.Spec.Replicas = &replicas
+
+	return patch
+}
+
+// NewEventsDatabasePodRolePatch returns a Patch that labels an Events Database pod with its role
+func NewEventsDatabasePodRolePatch(current *corev1.Pod, role string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	if current.Labels == nil {
+		current.Labels = map[string]string{}
+	}
+	current.Labels[EventsDatabaseRoleLabel] = role
+
+	return patch
+}
@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	util "github.com/atarazana/gramola-operator/util"
+)
+
+// Seed data files names
+const (
+	SeedDataSampleSetFilePrefix = "seed-data-"
+	SeedDataSampleSetFileSuffix = ".json"
+)
+
+// GetSeedDataSource returns where the seed data comes from as shown in the status, empty if there's no seed data
+func GetSeedDataSource(instance *gramolav1.AppService) string {
+	if instance.Spec.SeedData == nil {
+		return ""
+	}
+	if instance.Spec.SeedData.ConfigMap != nil && len(instance.Spec.SeedData.ConfigMap.Name) > 0 {
+		return "configmap/" + instance.Spec.SeedData.ConfigMap.Name
+	}
+	if len(instance.Spec.SeedData.SampleSet) > 0 {
+		return "sampleset/" + string(instance.Spec.SeedData.SampleSet)
+	}
+	return ""
+}
+
+// GetSeedDataSampleSet returns the JSON document with the events of the given sample set
+func GetSeedDataSampleSet(sampleSet gramolav1.SeedDataSampleSet) (string, error) {
+	return util.ReadFile(DbScriptsBasePath, SeedDataSampleSetFilePrefix+strings.ToLower(string(sampleSet))+SeedDataSampleSetFileSuffix)
+}
+
+// ParseSeedEvents returns the events in the given JSON document, an event or a list of them as posted to the gateway
+// /api/events, keyed by the columns of the event table. Events without a date get the given one, and the legacy date
+// and the start and end dates fill each other so that they can be loaded whatever the version of the schema
+func ParseSeedEvents(document string, date string) ([]map[string]interface{}, error) {
+	var documents []map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(document), "[") {
+		if err := json.Unmarshal([]byte(document), &documents); err != nil {
+			return nil, err
+		}
+	} else {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(document), &event); err != nil {
+			return nil, err
+		}
+		documents = append(documents, event)
+	}
+
+	events := []map[string]interface{}{}
+	for _, document := range documents {
+		event := map[string]interface{}{}
+		for key, value := range document {
+			// Ids are given by the database
+			if key == "id" {
+				continue
+			}
+			event[toColumnName(key)] = value
+		}
+		if _, found := event["date"]; !found {
+			event["date"] = date
+			if startDate, found := event["start_date"]; found {
+				event["date"] = startDate
+			}
+		}
+		if _, found := event["start_date"]; !found {
+			event["start_date"] = event["date"]
+		}
+		if _, found := event["end_date"]; !found {
+			event["end_date"] = event["start_date"]
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// toColumnName returns the snake case column name of the given camel case JSON key
+func toColumnName(key string) string {
+	var column strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				column.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		column.WriteRune(r)
+	}
+	return column.String()
+}
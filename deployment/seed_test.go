@@ -0,0 +1,94 @@
+package deployment
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSeedEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "single event without dates",
+			document: `{"id": 7, "name": "Opening night", "artistName": "The Band", "capacity": 250}`,
+			want: []map[string]interface{}{
+				{"name": "Opening night", "artist_name": "The Band", "capacity": float64(250),
+					"date": "2021-03-01", "start_date": "2021-03-01", "end_date": "2021-03-01"},
+			},
+		},
+		{
+			name: "list of events",
+			document: `
+				[
+					{"name": "Matinee", "startDate": "2021-04-10", "endDate": "2021-04-11"},
+					{"name": "Legacy", "date": "2021-05-01"}
+				]`,
+			want: []map[string]interface{}{
+				{"name": "Matinee", "date": "2021-04-10", "start_date": "2021-04-10", "end_date": "2021-04-11"},
+				{"name": "Legacy", "date": "2021-05-01", "start_date": "2021-05-01", "end_date": "2021-05-01"},
+			},
+		},
+		{
+			name:     "every date set",
+			document: `{"name": "Festival", "date": "2021-06-01", "startDate": "2021-06-02", "endDate": "2021-06-04"}`,
+			want: []map[string]interface{}{
+				{"name": "Festival", "date": "2021-06-01", "start_date": "2021-06-02", "end_date": "2021-06-04"},
+			},
+		},
+		{
+			name:     "empty list",
+			document: `[]`,
+			want:     []map[string]interface{}{},
+		},
+		{
+			name:     "invalid event",
+			document: `{"name": "Opening night"`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid list",
+			document: `[{"name": "Opening night"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "list of values",
+			document: `["Opening night"]`,
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseSeedEvents(test.document, "2021-03-01")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseSeedEvents() error = %v, wantErr %t", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseSeedEvents() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestToColumnName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "name", want: "name"},
+		{key: "startDate", want: "start_date"},
+		{key: "ArtistName", want: "artist_name"},
+		{key: "image_url", want: "image_url"},
+		{key: "", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if got := toColumnName(test.key); got != test.want {
+				t.Errorf("toColumnName(%q) = %q, want %q", test.key, got, test.want)
+			}
+		})
+	}
+}
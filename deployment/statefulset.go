@@ -0,0 +1,190 @@
+package deployment
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+
+	client "sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database StatefulSet names
+const (
+	EventsDatabaseHeadlessServiceName         = EventsDatabaseServiceName + "-headless"
+	EventsDatabaseStatefulSetMigrationJobName = EventsDatabaseServiceName + "-migrate-to-statefulset"
+
+	eventsDatabaseMigrationSourceVolumeName = "source"
+	eventsDatabaseMigrationSourceMountPath  = "/source"
+	eventsDatabaseMigrationTargetVolumeName = "target"
+	eventsDatabaseMigrationTargetMountPath  = "/target"
+)
+
+// EventsDatabaseTerminationGracePeriodSeconds time given to PostgreSQL to shut down cleanly
+var EventsDatabaseTerminationGracePeriodSeconds = int64(60)
+
+// IsEventsDatabaseStatefulSet returns true if the Events Database should run as a StatefulSet
+func IsEventsDatabaseStatefulSet(instance *gramolav1.AppService) bool {
+	return instance.Spec.Database != nil && instance.Spec.Database.Workload == gramolav1.DatabaseWorkloadStatefulSet
+}
+
+// EventsDatabaseStatefulSetPersistentVolumeClaimNameFor returns the name of the PVC the StatefulSet creates for the given pod ordinal
+func EventsDatabaseStatefulSetPersistentVolumeClaimNameFor(ordinal int) string {
+	return EventsDatabasePersistanceVolumeName + "-" + EventsDatabaseServiceName + "-" + strconv.Itoa(ordinal)
+}
+
+// NewEventsDatabaseStatefulSetPersistentVolumeClaim returns the PVC the StatefulSet would create for the given pod ordinal,
+// like those it isn't controlled by the AppService so that the data survives the StatefulSet
+func NewEventsDatabaseStatefulSetPersistentVolumeClaim(instance *gramolav1.AppService, ordinal int) *corev1.PersistentVolumeClaim {
+	return newEventsDatabasePersistentVolumeClaim(instance, EventsDatabaseStatefulSetPersistentVolumeClaimNameFor(ordinal))
+}
+
+// NewEventsDatabaseHeadlessService returns the headless Service that gives the StatefulSet pods a stable network identity
+func NewEventsDatabaseHeadlessService(instance *gramolav1.AppService, scheme *runtime.Scheme) (*corev1.Service, error) {
+	service, err := NewEventsDatabaseService(instance, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	service.Name = EventsDatabaseHeadlessServiceName
+	service.Spec.ClusterIP = corev1.ClusterIPNone
+	service.Spec.PublishNotReadyAddresses = true
+
+	return service, nil
+}
+
+// NewEventsDatabaseStatefulSet returns the Events Database as a StatefulSet, each pod gets its own PVC from spec.database.storage
+func NewEventsDatabaseStatefulSet(instance *gramolav1.AppService, scheme *runtime.Scheme) (*appsv1.StatefulSet, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseServiceName)
+	labels["app.kubernetes.io/name"] = "postgresql"
+
+	template := newEventsDatabasePodTemplate(instance, labels)
+	template.Spec.TerminationGracePeriodSeconds = &EventsDatabaseTerminationGracePeriodSeconds
+
+	// The data volume comes from the volume claim template
+	volumes := []corev1.Volume{}
+	for _, volume := range template.Spec.Volumes {
+		if volume.Name != EventsDatabasePersistanceVolumeName {
+			volumes = append(volumes, volume)
+		}
+	}
+	template.Spec.Volumes = volumes
+
+	volumeClaimTemplate := newEventsDatabasePersistentVolumeClaim(instance, EventsDatabasePersistanceVolumeName)
+
+	replicas := GetEventsDatabasePrimaryReplicas(instance)
+
+	statefulSet := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StatefulSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EventsDatabaseServiceName,
+			Namespace: instance.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &replicas,
+			Selector:            &metav1.LabelSelector{MatchLabels: labels},
+			ServiceName:         EventsDatabaseHeadlessServiceName,
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+			Template: template,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   volumeClaimTemplate.Name,
+						Labels: volumeClaimTemplate.Labels,
+					},
+					Spec: volumeClaimTemplate.Spec,
+				},
+			},
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(instance, statefulSet, scheme); err != nil {
+		return nil, err
+	}
+
+	return statefulSet, nil
+}
+
+// NewEventsDatabaseStatefulSetPatch returns a Patch
+func NewEventsDatabaseStatefulSetPatch(current *appsv1.StatefulSet, instance *gramolav1.AppService) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	// We could add version label but that would restart the DB
+	syncEventsDatabasePodTemplate(&current.Spec.Template, instance)
+
+	return patch
+}
+
+// NewEventsDatabaseDeploymentScalePatch returns a Patch that scales the Events Database Deployment to the given replicas
+func NewEventsDatabaseDeploymentScalePatch(current *appsv1.Deployment, replicas int32) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	current.Spec.Replicas = &replicas
+
+	return patch
+}
+
+// NewEventsDatabaseStatefulSetMigrationJob returns a Job that copies the data of the Events Database PVC into the PVC of
+// the first pod of the StatefulSet, the database must be stopped while it runs
+func NewEventsDatabaseStatefulSetMigrationJob(instance *gramolav1.AppService, scheme *runtime.Scheme) (*batchv1.Job, error) {
+	labels := GetAppServiceLabels(instance, EventsDatabaseStatefulSetMigrationJobName)
+
+	volumes := []corev1.Volume{
+		{
+			Name: eventsDatabaseMigrationSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: GetEventsDatabasePersistentVolumeClaimName(instance),
+					ReadOnly:  true,
+				},
+			},
+		},
+		{
+			Name: eventsDatabaseMigrationTargetVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: EventsDatabaseStatefulSetPersistentVolumeClaimNameFor(0),
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      eventsDatabaseMigrationSourceVolumeName,
+			MountPath: eventsDatabaseMigrationSourceMountPath,
+			ReadOnly:  true,
+		},
+		{
+			Name:      eventsDatabaseMigrationTargetVolumeName,
+			MountPath: eventsDatabaseMigrationTargetMountPath,
+		},
+	}
+
+	// Leftovers of a previous failed copy are removed first
+	command := "set -e\n" +
+		"find " + eventsDatabaseMigrationTargetMountPath + " -mindepth 1 -delete\n" +
+		"cp -a " + eventsDatabaseMigrationSourceMountPath + "/. " + eventsDatabaseMigrationTargetMountPath + "/\n" +
+		"du -sh " + eventsDatabaseMigrationTargetMountPath
+
+	job := newEventsDatabaseJob(instance, EventsDatabaseStatefulSetMigrationJobName, labels, command, volumes, volumeMounts)
+
+	if err := controllerutil.SetControllerReference(instance, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
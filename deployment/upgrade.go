@@ -0,0 +1,169 @@
+package deployment
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gramolav1 "github.com/atarazana/gramola-operator/api/v1"
+	// +kubebuilder:scaffold:imports
+)
+
+// Events Database versions
+const (
+	EventsDatabaseDefaultVersion = "10"
+
+	EventsDatabaseUpgradeJobNamePrefix = EventsDatabaseServiceName + "-upgrade-"
+)
+
+// EventsDatabaseImages maps the supported PostgreSQL major versions to the image that runs them
+var EventsDatabaseImages = map[string]string{
+	"10": EventsDatabaseServiceImage,
+	"12": "registry.access.redhat.com/rhscl/postgresql-12-rhel7:latest",
+	"13": "registry.access.redhat.com/rhscl/postgresql-13-rhel7:latest",
+}
+
+// GetEventsDatabaseVersion returns the PostgreSQL major version set in spec.database.version, 10 if not set
+func GetEventsDatabaseVersion(instance *gramolav1.AppService) string {
+	if instance.Spec.Database != nil && len(instance.Spec.Database.Version) > 0 {
+		return instance.Spec.Database.Version
+	}
+	return EventsDatabaseDefaultVersion
+}
+
+// GetEventsDatabaseImage returns the image of the PostgreSQL major version set in spec.database.version
+func GetEventsDatabaseImage(instance *gramolav1.AppService) string {
+	if image, ok := EventsDatabaseImages[GetEventsDatabaseVersion(instance)]; ok {
+		return image
+	}
+	return EventsDatabaseServiceImage
+}
+
+// GetEventsDatabaseImageVersion returns the PostgreSQL major version the given image runs, empty if it isn't known
+func GetEventsDatabaseImageVersion(image string) string {
+	for version, versionImage := range EventsDatabaseImages {
+		if versionImage == image {
+			return version
+		}
+	}
+	return ""
+}
+
+// GetEventsDatabaseContainerImage returns the image of the Events Database container of the given pod template
+func GetEventsDatabaseContainerImage(template *corev1.PodTemplateSpec) string {
+	for _, container := range template.Spec.Containers {
+		if container.Name == EventsDatabaseServiceContainerName {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// GetEventsDatabasePersistentVolumeClaimName returns the name of the PVC the Events Database Deployment keeps its data
+// in, the one of the last upgrade once the Deployment has been switched to it
+func GetEventsDatabasePersistentVolumeClaimName(instance *gramolav1.AppService) string {
+	if instance.Status.Database == nil || instance.Status.Database.Upgrade == nil {
+		return EventsDatabasePersistanceVolumeClaimName
+	}
+	upgrade := instance.Status.Database.Upgrade
+	switch upgrade.Phase {
+	case gramolav1.DatabaseUpgradePhaseSwitching, gramolav1.DatabaseUpgradePhaseAwaitingConfirmation, gramolav1.DatabaseUpgradePhaseSucceeded:
+		return upgrade.PersistentVolumeClaim
+	}
+	if len(upgrade.PreviousPersistentVolumeClaim) > 0 {
+		return upgrade.PreviousPersistentVolumeClaim
+	}
+	return EventsDatabasePersistanceVolumeClaimName
+}
+
+// EventsDatabaseUpgradePersistentVolumeClaimNameFor returns the name of the PVC the Events Database is upgraded into
+func EventsDatabaseUpgradePersistentVolumeClaimNameFor(version string) string {
+	return EventsDatabasePersistanceVolumeClaimName + "-" + version
+}
+
+// EventsDatabaseUpgradeJobNameFor returns the name of the Job that upgrades the Events Database to the given version
+func EventsDatabaseUpgradeJobNameFor(version string) string {
+	return EventsDatabaseUpgradeJobNamePrefix + version
+}
+
+// NewEventsDatabaseUpgradePersistentVolumeClaim returns the PVC the Events Database is upgraded into as set in spec.database.storage
+func NewEventsDatabaseUpgradePersistentVolumeClaim(instance *gramolav1.AppService, scheme *runtime.Scheme, version string) (*corev1.PersistentVolumeClaim, error) {
+	pvc := newEventsDatabasePersistentVolumeClaim(instance, EventsDatabaseUpgradePersistentVolumeClaimNameFor(version))
+
+	if err := controllerutil.SetControllerReference(instance, pvc, scheme); err != nil {
+		return nil, err
+	}
+
+	return pvc, nil
+}
+
+// NewEventsDatabaseUpgradeJob returns a Job that starts a database of the given version on the given PVC and restores
+// into it a dump of the running Events Database, the new database is stopped cleanly once the dump is restored. The
+// dump is taken with the client of the new version, which is able to read from older servers
+func NewEventsDatabaseUpgradeJob(instance *gramolav1.AppService, scheme *runtime.Scheme, version string, claimName string) (*batchv1.Job, error) {
+	jobName := EventsDatabaseUpgradeJobNameFor(version)
+	labels := GetAppServiceLabels(instance, jobName)
+
+	volumes := []corev1.Volume{
+		{
+			Name: EventsDatabasePersistanceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+				},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      EventsDatabasePersistanceVolumeName,
+			MountPath: EventsDatabaseDataMountPath,
+		},
+	}
+
+	port := strconv.Itoa(EventsDatabaseServicePort)
+
+	// The new database is initialized with the same credentials, so only the host changes between dump and restore
+	command := "set -eo pipefail\n" +
+		"run-postgresql &\n" +
+		"until pg_isready -h 127.0.0.1 -p " + port + "; do sleep 2; done\n" +
+		"pg_dump --clean --if-exists --no-owner | " + eventsDatabasePsqlCommand + " -h 127.0.0.1 -p " + port + "\n" +
+		"pg_ctl stop -D " + EventsDatabaseDataMountPath + "/userdata -m fast\n" +
+		"wait"
+
+	job := newEventsDatabaseJob(instance, jobName, labels, command, volumes, volumeMounts)
+	container := &job.Spec.Template.Spec.Containers[0]
+	container.Image = EventsDatabaseImages[version]
+	template := newEventsDatabasePodTemplate(instance, labels)
+	container.Env = append(template.Spec.Containers[0].Env, container.Env...)
+
+	if err := controllerutil.SetControllerReference(instance, job, scheme); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// NewEventsDatabaseUpgradePatch returns a Patch that switches the Events Database Deployment to the given image and PVC
+func NewEventsDatabaseUpgradePatch(current *appsv1.Deployment, image string, claimName string) client.Patch {
+	patch := client.MergeFrom(current.DeepCopy())
+
+	for i := range current.Spec.Template.Spec.Containers {
+		if current.Spec.Template.Spec.Containers[i].Name == EventsDatabaseServiceContainerName {
+			current.Spec.Template.Spec.Containers[i].Image = image
+		}
+	}
+	for i := range current.Spec.Template.Spec.Volumes {
+		volume := &current.Spec.Template.Spec.Volumes[i]
+		if volume.Name == EventsDatabasePersistanceVolumeName && volume.PersistentVolumeClaim != nil {
+			volume.PersistentVolumeClaim.ClaimName = claimName
+		}
+	}
+
+	return patch
+}
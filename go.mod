@@ -3,7 +3,9 @@ module github.com/atarazana/gramola-operator
 go 1.13
 
 require (
+	github.com/blang/semver v3.5.1+incompatible
 	github.com/go-logr/logr v0.1.0
+	github.com/lib/pq v1.10.9
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
 	github.com/openshift/api v3.9.0+incompatible
@@ -1,10 +1,12 @@
 package util
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 )
 
 // NVL returns def if str is null
@@ -38,3 +40,19 @@ func ReadFile(basePath string, fileName string) (string, error) {
 	}
 	return string(data), nil
 }
+
+const randomStringCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandomString returns a cryptographically secure random alphanumeric string of the given length
+func RandomString(length int) (string, error) {
+	max := big.NewInt(int64(len(randomStringCharset)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = randomStringCharset[n.Int64()]
+	}
+	return string(b), nil
+}